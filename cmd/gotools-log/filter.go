@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kbrownehs18/gotools/common"
+	"github.com/kbrownehs18/gotools/log"
+)
+
+// predicate field predicate: key=value, key!=value, key~regexp
+type predicate struct {
+	key    string
+	op     string
+	value  string
+	regexp *regexp.Regexp
+}
+
+func newPredicate(expr string) (*predicate, error) {
+	for _, op := range []string{"!=", "~", "="} {
+		i := strings.Index(expr, op)
+		if i <= 0 {
+			continue
+		}
+		p := &predicate{key: expr[:i], op: op, value: expr[i+len(op):]}
+		if op == "~" {
+			re, err := regexp.Compile(p.value)
+			if err != nil {
+				return nil, err
+			}
+			p.regexp = re
+		}
+		return p, nil
+	}
+
+	return nil, fmt.Errorf("field predicate [%s] error, expect key=value, key!=value or key~regexp", expr)
+}
+
+func (p *predicate) match(r *log.Record) bool {
+	v, ok := r.Fields[p.key]
+	switch p.op {
+	case "=":
+		return ok && v == p.value
+	case "!=":
+		return !ok || v != p.value
+	}
+
+	return ok && p.regexp.MatchString(v)
+}
+
+// filter record filter
+type filter struct {
+	since      time.Time
+	until      time.Time
+	minLevel   log.Level
+	levels     map[log.Level]bool
+	loggers    map[string]bool
+	predicates []*predicate
+	grep       *regexp.Regexp
+}
+
+// parseTime times without zone are local time, same as the log timestamps
+func parseTime(value string) (time.Time, error) {
+	t, err := common.StrToTime(value)
+	if err != nil {
+		return t, fmt.Errorf("time [%s] error: %s", value, err.Error())
+	}
+	if t.Location() == time.UTC {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(),
+			t.Second(), t.Nanosecond(), time.Local)
+	}
+
+	return t, nil
+}
+
+// setLevel one level means the minimum level, a comma separated list means exactly these levels
+func (f *filter) setLevel(value string) {
+	if value == "" {
+		return
+	}
+	names := strings.Split(value, ",")
+	if len(names) == 1 {
+		f.minLevel = log.NewLevel(names[0])
+		return
+	}
+	f.levels = make(map[log.Level]bool)
+	for _, name := range names {
+		f.levels[log.NewLevel(common.Trim(name))] = true
+	}
+}
+
+func (f *filter) setLoggers(value string) {
+	if value == "" {
+		return
+	}
+	f.loggers = make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		f.loggers[common.Trim(name)] = true
+	}
+}
+
+func (f *filter) match(r *log.Record) bool {
+	if !f.since.IsZero() && r.Time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !r.Time.Before(f.until) {
+		return false
+	}
+	if f.levels != nil {
+		if !f.levels[r.Level] {
+			return false
+		}
+	} else if r.Level < f.minLevel {
+		return false
+	}
+	if f.loggers != nil && !f.loggers[r.Logger] {
+		return false
+	}
+	for _, p := range f.predicates {
+		if !p.match(r) {
+			return false
+		}
+	}
+	if f.grep != nil && !f.grep.MatchString(r.Message) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,130 @@
+// gotools-log query and tail log files written by log.FileHandler
+//
+//	gotools-log [flags] logs/error.log
+//	gotools-log verify [-key key] logs/audit.log
+//
+// Backups (error.log.1, error.log.2006-01-02, also gzipped) are read oldest first,
+// then the active file. Text and json entries are both understood.
+// verify checks the HMAC chain of a log.AuditLogger file and its backups.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kbrownehs18/gotools/log"
+)
+
+type fieldFlags []string
+
+func (f *fieldFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fieldFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "gotools-log:", err)
+		os.Exit(1)
+	}
+}
+
+// run run the command, entries are printed to out
+func run(args []string, out io.Writer) error {
+	if len(args) > 0 && args[0] == "verify" {
+		return runVerify(args[1:], out)
+	}
+
+	fs := flag.NewFlagSet("gotools-log", flag.ContinueOnError)
+	follow := fs.Bool("f", false, "follow the log file across rotations")
+	last := fs.Int("n", -1, "print only the last n matched entries")
+	noBackup := fs.Bool("current", false, "read the active file only, skip backups")
+	since := fs.String("since", "", "entries at or after this time, e.g. \"2006-01-02 15:04:05\"")
+	until := fs.String("until", "", "entries before this time")
+	level := fs.String("level", "", "minimum level, or comma separated levels, e.g. warning or error,fatal")
+	logger := fs.String("logger", "", "comma separated logger names")
+	grep := fs.String("grep", "", "regexp the message must match")
+	jsonOutput := fs.Bool("json", false, "print entries as json")
+	interval := fs.Duration("interval", 250*time.Millisecond, "poll interval when following")
+	var fields fieldFlags
+	fs.Var(&fields, "field", "field predicate key=value, key!=value or key~regexp, repeatable")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gotools-log [flags] <log file>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("need one log file")
+	}
+	fileName := fs.Arg(0)
+
+	f := &filter{}
+	var err error
+	if *since != "" {
+		if f.since, err = parseTime(*since); err != nil {
+			return err
+		}
+	}
+	if *until != "" {
+		if f.until, err = parseTime(*until); err != nil {
+			return err
+		}
+	}
+	f.setLevel(*level)
+	f.setLoggers(*logger)
+	for _, expr := range fields {
+		p, err := newPredicate(expr)
+		if err != nil {
+			return err
+		}
+		f.predicates = append(f.predicates, p)
+	}
+	if *grep != "" {
+		if f.grep, err = regexp.Compile(*grep); err != nil {
+			return err
+		}
+	}
+
+	e := &emitter{filter: f, json: *jsonOutput, out: out, last: *last}
+	if *follow && e.last < 0 {
+		// like tail -f
+		e.last = 10
+	}
+
+	files := []string{fileName}
+	if !*noBackup {
+		if files, err = log.BackupFiles(fileName); err != nil {
+			return err
+		}
+	}
+	for _, name := range files {
+		if filepath.Clean(name) == filepath.Clean(fileName) {
+			continue
+		}
+		if err := readFile(name, e); err != nil {
+			return err
+		}
+	}
+
+	if err := tailFile(fileName, e, *follow, *interval); err != nil {
+		return err
+	}
+	// the last entry is complete once the file is read
+	e.flush()
+	e.stream()
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testLog = `[App][INFO]2026/10/19 10:00:00 main.go:1: started user=alice
+[App][ERROR]2026/10/19 11:00:00 main.go:2: failed user=bob
+    at main.go:12
+[App][WARNING]2026/10/19 12:00:00 main.go:3: slow user=bob
+`
+
+func TestRun(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "app.log")
+	if err := ioutil.WriteFile(fileName, []byte(testLog), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		args     []string
+		expected []string
+	}{
+		{nil, []string{"started", "failed", "at main.go:12", "slow"}},
+		{[]string{"-n", "2"}, []string{"failed", "at main.go:12", "slow"}},
+		{[]string{"-n", "1"}, []string{"slow"}},
+		{[]string{"-level", "error"}, []string{"failed", "at main.go:12"}},
+		{[]string{"-level", "info,warning"}, []string{"started", "slow"}},
+		{[]string{"-field", "user=bob"}, []string{"failed", "at main.go:12", "slow"}},
+		{[]string{"-field", "user!=bob"}, []string{"started"}},
+		{[]string{"-since", "2026-10-19 11:30:00"}, []string{"slow"}},
+		{[]string{"-since", "2026-10-19 10:30:00", "-until", "2026-10-19 11:30:00"}, []string{"failed", "at main.go:12"}},
+	} {
+		var out bytes.Buffer
+		if err := run(append(c.args, fileName), &out); err != nil {
+			t.Fatalf("%v: %v", c.args, err)
+		}
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(lines) != len(c.expected) {
+			t.Errorf("%v: %q, expected %q", c.args, lines, c.expected)
+			continue
+		}
+		for i, s := range c.expected {
+			if !strings.Contains(lines[i], s) {
+				t.Errorf("%v: %q, expected %q", c.args, lines, c.expected)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kbrownehs18/gotools/log"
+)
+
+// pendingIdle a followed record is complete when no line was read for this long
+const pendingIdle = time.Second
+
+// emitter group lines into records, filter and print them
+type emitter struct {
+	filter  *filter
+	json    bool
+	out     io.Writer
+	pending *log.Record
+	// lineTime time of the last line
+	lineTime time.Time
+	// last keep only the last n records, -1 print immediately
+	last    int
+	records []*log.Record
+}
+
+func (e *emitter) line(s string) {
+	e.lineTime = time.Now()
+	if r, ok := log.ParseRecord(s); ok {
+		e.flush()
+		e.pending = r
+	} else if e.pending != nil {
+		e.pending.Append(s)
+	}
+}
+
+func (e *emitter) flush() {
+	r := e.pending
+	e.pending = nil
+	if r == nil || !e.filter.match(r) {
+		return
+	}
+	if e.last < 0 {
+		e.print(r)
+		return
+	}
+	if e.last == 0 {
+		return
+	}
+	if len(e.records) == e.last {
+		e.records = e.records[1:]
+	}
+	e.records = append(e.records, r)
+}
+
+// stream print the kept records and print following records immediately,
+// the pending record may still get lines and is printed once complete
+func (e *emitter) stream() {
+	if e.pending != nil && e.last > 0 && len(e.records) == e.last {
+		e.records = e.records[1:]
+	}
+	for _, r := range e.records {
+		e.print(r)
+	}
+	e.records = nil
+	e.last = -1
+}
+
+func (e *emitter) print(r *log.Record) {
+	if !e.json {
+		fmt.Fprintln(e.out, r.Raw)
+		return
+	}
+
+	m := make(map[string]string, len(r.Fields)+5)
+	for k, v := range r.Fields {
+		m[k] = v
+	}
+	m["time"] = r.Time.Format(time.RFC3339)
+	m["level"] = r.Level.String()
+	m["logger"] = r.Logger
+	m["caller"] = r.Caller
+	m["msg"] = r.Message
+	b, _ := json.Marshal(m)
+	fmt.Fprintln(e.out, string(b))
+}
+
+// readLines read complete lines, return the unterminated rest
+func readLines(r *bufio.Reader, rest string, e *emitter) (string, error) {
+	for {
+		s, err := r.ReadString('\n')
+		rest += s
+		if err != nil {
+			return rest, err
+		}
+		e.line(rest)
+		rest = ""
+	}
+}
+
+// readFile read a whole log file, gzipped backups are decompressed
+func readFile(name string, e *emitter) error {
+	fd, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	var r io.Reader = fd
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(fd)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err.Error())
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	rest, err := readLines(bufio.NewReader(r), "", e)
+	if err != io.EOF {
+		return err
+	}
+	if rest != "" {
+		e.line(rest)
+	}
+
+	return nil
+}
+
+// tailFile read the active log file, keep reading it across rotations if follow
+func tailFile(name string, e *emitter, follow bool, interval time.Duration) error {
+	if !follow {
+		return readFile(name, e)
+	}
+
+	fd, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		fd.Close()
+	}()
+
+	r := bufio.NewReader(fd)
+	var rest string
+	history := true
+	for {
+		rest, err = readLines(r, rest, e)
+		if err != io.EOF {
+			return err
+		}
+		if history {
+			e.stream()
+			history = false
+		} else if rest == "" && time.Since(e.lineTime) >= pendingIdle {
+			// no line is being written, the pending record is complete
+			e.flush()
+		}
+
+		time.Sleep(interval)
+
+		cur, err := fd.Stat()
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			// rotating, file is not created yet
+			continue
+		}
+
+		if !os.SameFile(cur, info) {
+			// rotated, drain the old file and reopen
+			if rest, err = readLines(r, rest, e); err != io.EOF {
+				return err
+			}
+			if rest != "" {
+				e.line(rest)
+				rest = ""
+			}
+			nfd, err := os.Open(name)
+			if err != nil {
+				continue
+			}
+			fd.Close()
+			fd = nfd
+			r.Reset(fd)
+			continue
+		}
+
+		offset, err := fd.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if info.Size() < offset {
+			// truncated
+			if _, err = fd.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			r.Reset(fd)
+			rest = ""
+		}
+	}
+}
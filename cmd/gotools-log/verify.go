@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kbrownehs18/gotools/log"
+)
+
+// runVerify gotools-log verify [-key key] logs/audit.log
+func runVerify(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("gotools-log verify", flag.ContinueOnError)
+	key := fs.String("key", os.Getenv("GOTOOLS_AUDIT_KEY"), "HMAC key, default $GOTOOLS_AUDIT_KEY")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gotools-log verify [-key key] <audit log file>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("need one audit log file")
+	}
+	if *key == "" {
+		return fmt.Errorf("need the HMAC key")
+	}
+
+	result, err := log.VerifyAudit(fs.Arg(0), *key)
+	if err != nil {
+		return err
+	}
+	for _, file := range result.Stale {
+		fmt.Fprintln(out, "skipped", file, "before the checkpoint")
+	}
+	for _, file := range result.Files {
+		fmt.Fprintln(out, "checked", file)
+	}
+	if result.Broken != nil {
+		return fmt.Errorf("chain broken after %d entries: %s", result.Entries, result.Broken.Error())
+	}
+	if result.Checkpoint > 0 {
+		fmt.Fprintf(out, "entries 1 to %d removed by the rotation\n", result.Checkpoint)
+	}
+	fmt.Fprintf(out, "chain intact, %d entries, seq %d to %d\n", result.Entries, result.FirstSeq, result.LastSeq)
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+// runKey gotools key generate|convert|public|info
+func runKey(args []string) error {
+	if len(args) == 0 {
+		keyUsage()
+		return fmt.Errorf("need a key command")
+	}
+	switch args[0] {
+	case "generate":
+		return runKeyGenerate(args[1:])
+	case "convert":
+		return runKeyConvert(args[1:])
+	case "public":
+		return runKeyPublic(args[1:])
+	case "info":
+		return runKeyInfo(args[1:])
+	}
+	keyUsage()
+	return fmt.Errorf("unknown key command %s", args[0])
+}
+
+func keyUsage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  gotools key generate [-type rsa|ec256|ec384|ed25519] [-bits 2048] [-out name]")
+	fmt.Fprintln(os.Stderr, "  gotools key convert -to pkcs1|pkcs8|sec1|pkix <key file>")
+	fmt.Fprintln(os.Stderr, "  gotools key public [-to pkix|pkcs1] <private key file>")
+	fmt.Fprintln(os.Stderr, "  gotools key info <key file>")
+}
+
+// runKeyGenerate write the private key to name and the public key to name.pub, or both to stdout
+func runKeyGenerate(args []string) error {
+	fs := flag.NewFlagSet("gotools key generate", flag.ContinueOnError)
+	keyType := fs.String("type", "rsa", "key type: rsa, ec256, ec384 or ed25519")
+	bits := fs.Int("bits", 2048, "rsa key size")
+	out := fs.String("out", "", "private key file, the public key goes to <out>.pub, stdout if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	t, err := common.NewKeyType(*keyType)
+	if err != nil {
+		return err
+	}
+
+	priv, pub, err := common.GenerateKey(t, *bits)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		os.Stdout.Write(priv)
+		os.Stdout.Write(pub)
+		return nil
+	}
+	if err := ioutil.WriteFile(*out, priv, 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(*out+".pub", pub, 0644)
+}
+
+// runKeyConvert print the key converted to another form
+func runKeyConvert(args []string) error {
+	fs := flag.NewFlagSet("gotools key convert", flag.ContinueOnError)
+	to := fs.String("to", "", "target form: pkcs1, pkcs8, sec1 or pkix")
+	key, err := parseKeyArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	certType, err := common.NewCertType(*to)
+	if err != nil {
+		return err
+	}
+
+	_, private, err := common.DetectCertType(key)
+	if err != nil {
+		return err
+	}
+	var b []byte
+	if private {
+		b, err = common.ConvertPrivateKey(key, certType)
+	} else {
+		b, err = common.ConvertPublicKey(key, certType)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
+// runKeyPublic print the public key of a private key
+func runKeyPublic(args []string) error {
+	fs := flag.NewFlagSet("gotools key public", flag.ContinueOnError)
+	to := fs.String("to", "pkix", "public key form: pkix or pkcs1")
+	key, err := parseKeyArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	certType, err := common.NewCertType(*to)
+	if err != nil {
+		return err
+	}
+
+	b, err := common.PublicKey(key, certType)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
+// runKeyInfo print the form, algorithm and size of a key
+func runKeyInfo(args []string) error {
+	fs := flag.NewFlagSet("gotools key info", flag.ContinueOnError)
+	key, err := parseKeyArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	certType, private, err := common.DetectCertType(key)
+	if err != nil {
+		return err
+	}
+
+	kind := "public"
+	var pub interface{}
+	if private {
+		kind = "private"
+		b, err := common.PublicKey(key)
+		if err != nil {
+			return err
+		}
+		key = b
+	}
+	block, _ := pem.Decode(key)
+	if certType == common.PKCS1 && !private {
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	} else {
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		fmt.Printf("%s %s key, rsa %d bits\n", certType, kind, k.N.BitLen())
+	case *ecdsa.PublicKey:
+		fmt.Printf("%s %s key, ecdsa %s\n", certType, kind, k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		fmt.Printf("%s %s key, ed25519\n", certType, kind)
+	default:
+		fmt.Printf("%s %s key, %T\n", certType, kind, k)
+	}
+	return nil
+}
+
+// parseKeyArgs parse flags and read the key file argument
+func parseKeyArgs(fs *flag.FlagSet, args []string) ([]byte, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 1 {
+		keyUsage()
+		return nil, fmt.Errorf("need one key file")
+	}
+	return ioutil.ReadFile(fs.Arg(0))
+}
@@ -0,0 +1,39 @@
+// gotools command line helpers of the gotools packages
+//
+//	gotools key generate [-type rsa|ec256|ec384|ed25519] [-bits 2048] [-out name]
+//	gotools key convert -to pkcs1|pkcs8|sec1|pkix key.pem
+//	gotools key public [-to pkix|pkcs1] private.pem
+//	gotools key info key.pem
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		usage()
+		return fmt.Errorf("need a command")
+	}
+	switch args[0] {
+	case "key":
+		return runKey(args[1:])
+	}
+	usage()
+	return fmt.Errorf("unknown command %s", args[0])
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: gotools <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  key    generate, convert and inspect PEM keys")
+}
@@ -0,0 +1,164 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// AEADAlgorithm algorithm of an AEAD key
+type AEADAlgorithm byte
+
+const (
+	// AES256GCM AES-256 in GCM mode
+	AES256GCM AEADAlgorithm = iota + 1
+	// ChaCha20Poly1305 ChaCha20-Poly1305, faster than AES without hardware support
+	ChaCha20Poly1305
+)
+
+// aeadVersion version of the ciphertext header
+const aeadVersion = 1
+
+// AEADKeySize key size of all algorithms
+const AEADKeySize = 32
+
+// ErrDecrypt ciphertext is not authentic, modified or encrypted with another key
+var ErrDecrypt = errors.New("decrypt failed")
+
+// AEAD authenticated encryption with random nonces. Ciphertexts carry a header with the format version
+// and the id of the key, so data encrypted with older keys can still be decrypted after rotation:
+//
+//	version (1 byte) | key id (1 byte) | nonce (12 bytes) | sealed data and tag
+//
+// Keys should be rotated before 2^32 messages are encrypted with one key.
+type AEAD struct {
+	keys    map[byte]cipher.AEAD
+	current byte
+}
+
+// NewAEADKey new random key
+func NewAEADKey() ([]byte, error) {
+	key := make([]byte, AEADKeySize)
+	if _, err := crand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewAEAD new AEAD encrypting with the 32 bytes key of keyID
+func NewAEAD(algorithm AEADAlgorithm, keyID byte, key []byte) (*AEAD, error) {
+	a := &AEAD{keys: make(map[byte]cipher.AEAD)}
+	if err := a.AddKey(algorithm, keyID, key); err != nil {
+		return nil, err
+	}
+	a.current = keyID
+	return a, nil
+}
+
+// AddKey add an older key used only to decrypt
+func (a *AEAD) AddKey(algorithm AEADAlgorithm, keyID byte, key []byte) error {
+	if len(key) != AEADKeySize {
+		return fmt.Errorf("aead key must be %d bytes", AEADKeySize)
+	}
+	if _, ok := a.keys[keyID]; ok {
+		return fmt.Errorf("aead key %d exists", keyID)
+	}
+
+	aead, err := newCipherAEAD(algorithm, key)
+	if err != nil {
+		return err
+	}
+	a.keys[keyID] = aead
+	return nil
+}
+
+func newCipherAEAD(algorithm AEADAlgorithm, key []byte) (cipher.AEAD, error) {
+	switch algorithm {
+	case AES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case ChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	}
+	return nil, errors.New("unknown aead algorithm")
+}
+
+// Encrypt encrypt plaintext with the current key, additionalData is authenticated but not encrypted,
+// the same additionalData must be given to Decrypt
+func (a *AEAD) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	aead := a.keys[a.current]
+	out := make([]byte, 2+aead.NonceSize(), 2+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out[0], out[1] = aeadVersion, a.current
+	if _, err := crand.Read(out[2:]); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out[2:], plaintext, aeadAdditionalData(out[:2], additionalData)), nil
+}
+
+// Decrypt decrypt ciphertext of Encrypt with the key of its header
+func (a *AEAD) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < 2 || ciphertext[0] != aeadVersion {
+		return nil, ErrDecrypt
+	}
+	aead, ok := a.keys[ciphertext[1]]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %d", ErrDecrypt, ciphertext[1])
+	}
+	if len(ciphertext) < 2+aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	nonce := ciphertext[2 : 2+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[2+aead.NonceSize():], aeadAdditionalData(ciphertext[:2], additionalData))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// EncryptBase64 Encrypt to standard base64
+func (a *AEAD) EncryptBase64(plaintext, additionalData []byte) (string, error) {
+	b, err := a.Encrypt(plaintext, additionalData)
+	if err != nil {
+		return "", err
+	}
+	return Base64Encode(b), nil
+}
+
+// DecryptBase64 Decrypt standard base64 of EncryptBase64
+func (a *AEAD) DecryptBase64(ciphertext string, additionalData []byte) ([]byte, error) {
+	b, err := Base64Decode(ciphertext)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return a.Decrypt(b, additionalData)
+}
+
+// EncryptURLBase64 Encrypt to url safe base64 for urls and cookies
+func (a *AEAD) EncryptURLBase64(plaintext, additionalData []byte) (string, error) {
+	b, err := a.Encrypt(plaintext, additionalData)
+	if err != nil {
+		return "", err
+	}
+	return URLBase64Encode(b), nil
+}
+
+// DecryptURLBase64 Decrypt url safe base64 of EncryptURLBase64
+func (a *AEAD) DecryptURLBase64(ciphertext string, additionalData []byte) ([]byte, error) {
+	b, err := URLBase64Decode(ciphertext)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return a.Decrypt(b, additionalData)
+}
+
+// aeadAdditionalData authenticate the header with the additional data
+func aeadAdditionalData(header, additionalData []byte) []byte {
+	return append(append(make([]byte, 0, len(header)+len(additionalData)), header...), additionalData...)
+}
@@ -0,0 +1,130 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAuthcode authcode text is malformed, expired or encoded with another key
+var ErrAuthcode = errors.New("authcode invalid or expired")
+
+// DefaultAuthcodeKeyLength dynamic key length of Discuz
+const DefaultAuthcodeKeyLength = 4
+
+// AuthcodeOptions options of AuthcodeEncode and AuthcodeDecode, compatible with Discuz authcode
+type AuthcodeOptions struct {
+	// Key secret key, UC_KEY of Discuz
+	Key string
+	// Expiry lifetime of the encoded text in whole seconds, 0 never expires
+	Expiry time.Duration
+	// DynamicKeyLength length of the random prefix making each encoded text different,
+	// DefaultAuthcodeKeyLength if 0, no prefix if negative, at most 32
+	DynamicKeyLength int
+	// Now current time, time.Now if nil
+	Now func() time.Time
+}
+
+func (o *AuthcodeOptions) keyLength() int {
+	switch {
+	case o.DynamicKeyLength == 0:
+		return DefaultAuthcodeKeyLength
+	case o.DynamicKeyLength < 0:
+		return 0
+	case o.DynamicKeyLength > 32:
+		return 32
+	}
+	return o.DynamicKeyLength
+}
+
+func (o *AuthcodeOptions) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
+
+// AuthcodeEncode encode text as Discuz authcode ENCODE
+func AuthcodeEncode(text string, opts AuthcodeOptions) (string, error) {
+	if opts.Key == "" {
+		return "", errors.New("authcode key is empty")
+	}
+	now := opts.now()
+	keyA, keyB := authcodeKeys(opts.Key)
+	var keyC string
+	if n := opts.keyLength(); n > 0 {
+		// md5 of microtime() like php
+		keyC = Md5Sum(fmt.Sprintf("0.%06d00 %d", now.Nanosecond()/1000, now.Unix()))[32-n:]
+	}
+
+	var expiry int64
+	if opts.Expiry > 0 {
+		expiry = now.Unix() + int64(opts.Expiry/time.Second)
+	}
+	b := authcodeRC4([]byte(fmt.Sprintf("%010d%s%s", expiry, Md5Sum(text + keyB)[0:16], text)), keyA, keyC)
+	// php strips the padding
+	return keyC + strings.TrimRight(Base64Encode(b), "="), nil
+}
+
+// AuthcodeDecode decode text of AuthcodeEncode or Discuz authcode, ErrAuthcode if it is not valid
+func AuthcodeDecode(text string, opts AuthcodeOptions) (string, error) {
+	if opts.Key == "" {
+		return "", errors.New("authcode key is empty")
+	}
+	n := opts.keyLength()
+	if len(text) < n {
+		return "", ErrAuthcode
+	}
+	keyA, keyB := authcodeKeys(opts.Key)
+	b, err := Base64Decode(text[n:])
+	if err != nil {
+		return "", ErrAuthcode
+	}
+	result := authcodeRC4(b, keyA, text[:n])
+	if len(result) < 26 {
+		return "", ErrAuthcode
+	}
+
+	expiry, err := strconv.ParseInt(string(result[0:10]), 10, 64)
+	if err != nil {
+		return "", ErrAuthcode
+	}
+	if expiry != 0 && expiry <= opts.now().Unix() {
+		return "", ErrAuthcode
+	}
+	if string(result[10:26]) != Md5Sum(string(result[26:]) + keyB)[0:16] {
+		return "", ErrAuthcode
+	}
+	return string(result[26:]), nil
+}
+
+// authcodeKeys keyA of encryption and keyB of validation
+func authcodeKeys(key string) (string, string) {
+	mKey := Md5Sum(key)
+	return Md5Sum(mKey[0:16]), Md5Sum(mKey[16:])
+}
+
+// authcodeRC4 rc4 of text with the key derived from keyA and the dynamic keyC
+func authcodeRC4(text []byte, keyA, keyC string) []byte {
+	cryptKey := []byte(keyA + Md5Sum(keyA+keyC))
+
+	box := RangeArray(0, 256)
+	j := 0
+	for i := 0; i < 256; i++ {
+		j = (j + box[i] + int(cryptKey[i%len(cryptKey)])) % 256
+		box[i], box[j] = box[j], box[i]
+	}
+
+	a := 0
+	j = 0
+	result := make([]byte, len(text))
+	for i := range text {
+		a = (a + 1) % 256
+		j = (j + box[a]) % 256
+		box[a], box[j] = box[j], box[a]
+		result[i] = text[i] ^ byte(box[(box[a]+box[j])%256])
+	}
+	return result
+}
@@ -0,0 +1,270 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// CassetteMode whether a Cassette replays or records
+type CassetteMode int
+
+const (
+	// ModeReplay replay recorded interactions, requests without a match fail
+	ModeReplay CassetteMode = iota
+	// ModeRecord send requests and record them, replacing the cassette file
+	ModeRecord
+	// ModeOnce replay if the cassette file exists, record it otherwise
+	ModeOnce
+)
+
+// Redacted value of redacted headers in cassette files
+const Redacted = "REDACTED"
+
+// ErrNoInteraction no recorded interaction matches the request
+var ErrNoInteraction = errors.New("cassette: no recorded interaction matches the request")
+
+// RecordedRequest request of an Interaction
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	// BodyBase64 Body is base64 encoded, for bodies that are not utf-8
+	BodyBase64 bool `json:"body_base64,omitempty"`
+}
+
+// RecordedResponse response of an Interaction
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 bool        `json:"body_base64,omitempty"`
+}
+
+// Interaction recorded request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Matcher whether the recorded request matches req with body
+type Matcher func(req *http.Request, body []byte, recorded *RecordedRequest) bool
+
+// MatchMethodURL match method and url
+func MatchMethodURL(req *http.Request, body []byte, recorded *RecordedRequest) bool {
+	return req.Method == recorded.Method && req.URL.String() == recorded.URL
+}
+
+// MatchMethodURLBody match method, url and body, the default Matcher
+func MatchMethodURLBody(req *http.Request, body []byte, recorded *RecordedRequest) bool {
+	if !MatchMethodURL(req, body, recorded) {
+		return false
+	}
+	b, err := decodeBody(recorded.Body, recorded.BodyBase64)
+	return err == nil && bytes.Equal(b, body)
+}
+
+// Cassette http.RoundTripper recording real interactions to a JSON file and replaying them
+// so tests run without network. Each recorded interaction is replayed once in order,
+// the last match is replayed again when all are used.
+//
+//	cassette, err := common.NewCassette("testdata/api.json", common.ModeOnce)
+//	client := &http.Client{Transport: cassette}
+type Cassette struct {
+	// Matcher MatchMethodURLBody if nil
+	Matcher Matcher
+	// Redact headers recorded as REDACTED, Authorization, Cookie and Set-Cookie by default
+	Redact []string
+	// Transport sends requests when recording, http.DefaultTransport if nil
+	Transport http.RoundTripper
+
+	path         string
+	mode         CassetteMode
+	lock         sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// NewCassette open the cassette file path, in ModeReplay it must exist
+func NewCassette(path string, mode CassetteMode) (*Cassette, error) {
+	c := &Cassette{
+		Redact: []string{"Authorization", "Cookie", "Set-Cookie"},
+		path:   path,
+		mode:   mode,
+	}
+	if mode == ModeRecord {
+		return c, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && mode == ModeOnce {
+		c.mode = ModeRecord
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.interactions); err != nil {
+		return nil, fmt.Errorf("cassette %s: %s", path, err.Error())
+	}
+	c.mode = ModeReplay
+	c.used = make([]bool, len(c.interactions))
+	return c, nil
+}
+
+// Recording whether requests are sent and recorded
+func (c *Cassette) Recording() bool {
+	return c.mode == ModeRecord
+}
+
+// RoundTrip implements http.RoundTripper
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	if c.mode == ModeRecord {
+		return c.record(req, body)
+	}
+	return c.replay(req, body)
+}
+
+func (c *Cassette) replay(req *http.Request, body []byte) (*http.Response, error) {
+	matcher := c.Matcher
+	if matcher == nil {
+		matcher = MatchMethodURLBody
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	match := -1
+	for i, in := range c.interactions {
+		if !matcher(req, body, &in.Request) {
+			continue
+		}
+		match = i
+		if !c.used[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL)
+	}
+	c.used[match] = true
+
+	recorded := c.interactions[match].Response
+	b, err := decodeBody(recorded.Body, recorded.BodyBase64)
+	if err != nil {
+		return nil, err
+	}
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode:    recorded.StatusCode,
+		Status:        recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}, nil
+}
+
+func (c *Cassette) record(req *http.Request, body []byte) (*http.Response, error) {
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	r := req.Clone(req.Context())
+	if body != nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	res, err := transport.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	in := &Interaction{
+		Request:  RecordedRequest{Method: req.Method, URL: req.URL.String(), Header: c.redact(req.Header)},
+		Response: RecordedResponse{StatusCode: res.StatusCode, Status: res.Status, Header: c.redact(res.Header)},
+	}
+	in.Request.Body, in.Request.BodyBase64 = encodeBody(body)
+	in.Response.Body, in.Response.BodyBase64 = encodeBody(b)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.interactions = append(c.interactions, in)
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// save write all interactions, the file is replaced after each request
+func (c *Cassette) save() error {
+	b, err := json.MarshalIndent(c.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+func (c *Cassette) redact(header http.Header) http.Header {
+	h := header.Clone()
+	for k := range h {
+		for _, name := range c.Redact {
+			if strings.EqualFold(k, name) {
+				h[k] = []string{Redacted}
+			}
+		}
+	}
+	return h
+}
+
+func encodeBody(b []byte) (string, bool) {
+	if utf8.Valid(b) {
+		return string(b), false
+	}
+	return base64.StdEncoding.EncodeToString(b), true
+}
+
+func decodeBody(s string, isBase64 bool) ([]byte, error) {
+	if isBase64 {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return []byte(s), nil
+}
@@ -0,0 +1,222 @@
+package common
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar http.CookieJar that can be saved to and loaded from a file,
+// domains are not checked against the public suffix list
+type CookieJar struct {
+	lock    sync.Mutex
+	cookies map[string]*JarCookie
+}
+
+// JarCookie cookie stored by CookieJar
+type JarCookie struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Domain string `json:"domain"`
+	Path   string `json:"path"`
+	// Expires zero for session cookies
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HTTPOnly bool      `json:"http_only,omitempty"`
+	// HostOnly sent to Domain only, not to its subdomains
+	HostOnly bool `json:"host_only,omitempty"`
+}
+
+// NewCookieJar new empty cookie jar
+func NewCookieJar() *CookieJar {
+	return &CookieJar{cookies: make(map[string]*JarCookie)}
+}
+
+func (c *JarCookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c *JarCookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// SetCookies implements http.CookieJar
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := canonicalHost(u.Host)
+	now := time.Now()
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	for _, cookie := range cookies {
+		c := &JarCookie{Name: cookie.Name, Value: cookie.Value, Path: cookie.Path,
+			Secure: cookie.Secure, HTTPOnly: cookie.HttpOnly}
+
+		domain := strings.ToLower(strings.TrimPrefix(cookie.Domain, "."))
+		switch {
+		case domain == "":
+			c.Domain, c.HostOnly = host, true
+		case host == domain || (strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil):
+			c.Domain = domain
+		default:
+			// cookie for another domain
+			continue
+		}
+		if c.Path == "" || c.Path[0] != '/' {
+			c.Path = defaultCookiePath(u.Path)
+		}
+
+		switch {
+		case cookie.MaxAge < 0:
+			c.Expires = now
+		case cookie.MaxAge > 0:
+			c.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		case !cookie.Expires.IsZero():
+			c.Expires = cookie.Expires
+		}
+
+		if c.expired(now) {
+			delete(j.cookies, c.key())
+		} else {
+			j.cookies[c.key()] = c
+		}
+	}
+}
+
+// Cookies implements http.CookieJar, longer paths first
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	host := canonicalHost(u.Host)
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	secure := u.Scheme == "https"
+	now := time.Now()
+
+	j.lock.Lock()
+	var matched []*JarCookie
+	for key, c := range j.cookies {
+		if c.expired(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		if c.Secure && !secure {
+			continue
+		}
+		if c.HostOnly && host != c.Domain {
+			continue
+		}
+		if !c.HostOnly && host != c.Domain && !strings.HasSuffix(host, "."+c.Domain) {
+			continue
+		}
+		if !cookiePathMatch(path, c.Path) {
+			continue
+		}
+		matched = append(matched, c)
+	}
+	j.lock.Unlock()
+
+	sort.Slice(matched, func(a, b int) bool {
+		if len(matched[a].Path) != len(matched[b].Path) {
+			return len(matched[a].Path) > len(matched[b].Path)
+		}
+		return matched[a].Name < matched[b].Name
+	})
+	cookies := make([]*http.Cookie, len(matched))
+	for i, c := range matched {
+		cookies[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+	return cookies
+}
+
+// All cookies of the jar that have not expired, sorted by domain, path and name
+func (j *CookieJar) All() []JarCookie {
+	now := time.Now()
+	j.lock.Lock()
+	cookies := make([]JarCookie, 0, len(j.cookies))
+	for _, c := range j.cookies {
+		if !c.expired(now) {
+			cookies = append(cookies, *c)
+		}
+	}
+	j.lock.Unlock()
+
+	sort.Slice(cookies, func(a, b int) bool {
+		return cookies[a].key() < cookies[b].key()
+	})
+	return cookies
+}
+
+// Save write the cookies to fileName as JSON, session cookies included
+func (j *CookieJar) Save(fileName string) error {
+	b, err := json.MarshalIndent(j.All(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		return err
+	}
+	// cookies are credentials
+	tmp := fileName + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(b, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
+
+// Load add the cookies saved to fileName, a missing file is not an error
+func (j *CookieJar) Load(fileName string) error {
+	b, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cookies []*JarCookie
+	if err := json.Unmarshal(b, &cookies); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	for _, c := range cookies {
+		if !c.expired(now) {
+			j.cookies[c.key()] = c
+		}
+	}
+	return nil
+}
+
+// canonicalHost lower case host without port
+func canonicalHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// defaultCookiePath directory of the request path, RFC 6265 5.1.4
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// cookiePathMatch RFC 6265 5.1.4
+func cookiePathMatch(path, cookiePath string) bool {
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+	return len(path) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
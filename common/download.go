@@ -0,0 +1,382 @@
+package common
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrChecksum downloaded file does not match the checksum
+var ErrChecksum = errors.New("checksum mismatch")
+
+// Download streams url to a file through fileName.part, when the server supports ranges
+// an interrupted download resumes from the state saved in fileName.part.json
+//
+//	n, err := common.NewDownload(url, "/data/app.tar.gz").Chunks(4).SHA256(sum).Do(ctx)
+type Download struct {
+	url      string
+	fileName string
+	header   http.Header
+	client   *http.Client
+	chunks   int
+	hash     func() hash.Hash
+	checksum string
+	progress func(done, total int64)
+}
+
+// downloadState progress of a ranged download
+type downloadState struct {
+	URL  string `json:"url"`
+	Size int64  `json:"size"`
+	// Validator ETag or Last-Modified of the remote file
+	Validator string           `json:"validator"`
+	Chunks    []*downloadChunk `json:"chunks"`
+}
+
+// downloadChunk bytes Start to End inclusive, Done of them written
+type downloadChunk struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Done  int64 `json:"done"`
+}
+
+// saveStateEvery bytes written between saves of the state
+const saveStateEvery = 4 << 20
+
+// NewDownload new download of url to fileName
+func NewDownload(url, fileName string) *Download {
+	return &Download{url: url, fileName: fileName, header: http.Header{}, chunks: 1}
+}
+
+// Header set a request header
+func (d *Download) Header(key, value string) *Download {
+	d.header.Set(key, value)
+	return d
+}
+
+// Client send with client instead of http.DefaultClient
+func (d *Download) Client(client *http.Client) *Download {
+	d.client = client
+	return d
+}
+
+// Chunks download n ranges in parallel when the server supports ranges
+func (d *Download) Chunks(n int) *Download {
+	if n < 1 {
+		n = 1
+	}
+	d.chunks = n
+	return d
+}
+
+// MD5 verify the file against the hex md5 sum
+func (d *Download) MD5(sum string) *Download {
+	d.hash, d.checksum = md5.New, sum
+	return d
+}
+
+// SHA256 verify the file against the hex sha256 sum
+func (d *Download) SHA256(sum string) *Download {
+	d.hash, d.checksum = sha256.New, sum
+	return d
+}
+
+// Progress call fn after each write, total is -1 if unknown, fn is not called concurrently
+func (d *Download) Progress(fn func(done, total int64)) *Download {
+	d.progress = fn
+	return d
+}
+
+// Do download the file, the file is replaced only when the download is complete and verified
+func (d *Download) Do(ctx context.Context) (written int64, err error) {
+	part := d.fileName + ".part"
+	stateFile := part + ".json"
+
+	size, validator, ranges := d.probe(ctx)
+	if ranges && size > 0 {
+		written, err = d.ranged(ctx, part, stateFile, size, validator)
+	} else {
+		os.Remove(stateFile)
+		written, err = d.stream(ctx, part)
+	}
+	if err != nil {
+		return written, err
+	}
+
+	if d.hash != nil {
+		if err := d.verify(part); err != nil {
+			os.Remove(part)
+			os.Remove(stateFile)
+			return written, err
+		}
+	}
+	if err := os.Rename(part, d.fileName); err != nil {
+		return written, err
+	}
+	os.Remove(stateFile)
+	return written, nil
+}
+
+func (d *Download) send(ctx context.Context, method Method, header http.Header) (*http.Response, error) {
+	r := NewRequest(d.url).Method(method)
+	for k, v := range d.header {
+		r.Header(k, v[0])
+	}
+	for k, v := range header {
+		r.Header(k, v[0])
+	}
+	req, err := r.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client := d.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// probe size, validator and range support of the remote file, size is -1 if unknown
+func (d *Download) probe(ctx context.Context) (int64, string, bool) {
+	res, err := d.send(ctx, HEAD, nil)
+	if err != nil {
+		return -1, "", false
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return -1, "", false
+	}
+
+	validator := res.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		// weak etags cannot be used with If-Range
+		validator = res.Header.Get("Last-Modified")
+	}
+	return res.ContentLength, validator, res.Header.Get("Accept-Ranges") == "bytes"
+}
+
+// stream download the whole file in one request
+func (d *Download) stream(ctx context.Context, part string) (int64, error) {
+	res, err := d.send(ctx, GET, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return 0, newHTTPError(res)
+	}
+
+	fd, err := os.Create(part)
+	if err != nil {
+		return 0, err
+	}
+	var w io.Writer = fd
+	if d.progress != nil {
+		w = &progressWriter{w: fd, total: res.ContentLength, fn: d.progress}
+	}
+	n, err := io.Copy(w, res.Body)
+	if e := fd.Close(); err == nil {
+		err = e
+	}
+	return n, err
+}
+
+// ranged download the chunks of the file in parallel, resuming from the saved state
+func (d *Download) ranged(ctx context.Context, part, stateFile string, size int64, validator string) (int64, error) {
+	state := loadDownloadState(stateFile)
+	var fd *os.File
+	var err error
+	if state != nil && validator != "" && state.URL == d.url && state.Size == size && state.Validator == validator {
+		fd, err = os.OpenFile(part, os.O_WRONLY, 0644)
+		if err == nil {
+			if info, e := fd.Stat(); e != nil || info.Size() != size {
+				fd.Close()
+				fd, err = nil, errors.New("part file changed")
+			}
+		}
+	}
+	if fd == nil || err != nil {
+		state = newDownloadState(d.url, size, validator, d.chunks)
+		if fd, err = os.Create(part); err != nil {
+			return 0, err
+		}
+		if err = fd.Truncate(size); err != nil {
+			fd.Close()
+			return 0, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var lock sync.Mutex
+	var done, unsaved int64
+	for _, c := range state.Chunks {
+		done += c.Done
+	}
+	save := func() error {
+		unsaved = 0
+		return state.save(stateFile)
+	}
+	written := func(c *downloadChunk, n int) {
+		lock.Lock()
+		defer lock.Unlock()
+		c.Done += int64(n)
+		done += int64(n)
+		if unsaved += int64(n); unsaved >= saveStateEvery {
+			save()
+		}
+		if d.progress != nil {
+			d.progress(done, size)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(state.Chunks))
+	for _, c := range state.Chunks {
+		if c.Start+c.Done > c.End {
+			continue
+		}
+		wg.Add(1)
+		go func(c *downloadChunk) {
+			defer wg.Done()
+			if err := d.chunk(ctx, fd, c, validator, written); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(c)
+	}
+	wg.Wait()
+	close(errs)
+
+	err = <-errs
+	if e := fd.Close(); err == nil {
+		err = e
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if err != nil {
+		save()
+		return done, err
+	}
+	return done, nil
+}
+
+// chunk download the rest of c
+func (d *Download) chunk(ctx context.Context, fd *os.File, c *downloadChunk, validator string,
+	written func(c *downloadChunk, n int)) error {
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", c.Start+c.Done, c.End))
+	if validator != "" {
+		header.Set("If-Range", validator)
+	}
+	res, err := d.send(ctx, GET, header)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return errors.New("download: remote file changed or ranges not supported")
+	}
+	if res.StatusCode != http.StatusPartialContent {
+		return newHTTPError(res)
+	}
+
+	buf := make([]byte, 32<<10)
+	offset := c.Start + c.Done
+	for offset <= c.End {
+		n, err := res.Body.Read(buf)
+		if int64(n) > c.End-offset+1 {
+			n = int(c.End - offset + 1)
+		}
+		if n > 0 {
+			if _, e := fd.WriteAt(buf[:n], offset); e != nil {
+				return e
+			}
+			offset += int64(n)
+			written(c, n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if offset <= c.End {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (d *Download) verify(fileName string) error {
+	fd, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	h := d.hash()
+	if _, err := io.Copy(h, fd); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != strings.ToLower(d.checksum) {
+		return fmt.Errorf("%w: %s, expected %s", ErrChecksum, sum, d.checksum)
+	}
+	return nil
+}
+
+func newDownloadState(url string, size int64, validator string, chunks int) *downloadState {
+	if int64(chunks) > size {
+		chunks = int(size)
+	}
+	state := &downloadState{URL: url, Size: size, Validator: validator}
+	n := size / int64(chunks)
+	for i := 0; i < chunks; i++ {
+		c := &downloadChunk{Start: int64(i) * n, End: int64(i+1)*n - 1}
+		if i == chunks-1 {
+			c.End = size - 1
+		}
+		state.Chunks = append(state.Chunks, c)
+	}
+	return state
+}
+
+func loadDownloadState(fileName string) *downloadState {
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil
+	}
+	state := &downloadState{}
+	if json.Unmarshal(b, state) != nil {
+		return nil
+	}
+	return state
+}
+
+func (s *downloadState) save(fileName string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := fileName + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
+
+// newHTTPError HTTPError with the start of the body
+func newHTTPError(res *http.Response) *HTTPError {
+	b, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1<<10))
+	return &HTTPError{StatusCode: res.StatusCode, Status: res.Status, Header: res.Header, Body: b}
+}
@@ -6,18 +6,16 @@ import (
 	"crypto/md5"
 	crand "crypto/rand"
 	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/sha256"
-	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/pem"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
-	"log"
 	"math/rand"
-	"mime/multipart"
 	"net"
 	"net/http"
 	httpurl "net/url"
@@ -70,6 +68,20 @@ const (
 	POST Method = iota
 	// GET http request get method
 	GET
+	// PUT http request put method
+	PUT
+	// DELETE http request delete method
+	DELETE
+	// PATCH http request patch method
+	PATCH
+	// HEAD http request head method
+	HEAD
+	// OPTIONS http request options method
+	OPTIONS
+	// CONNECT http request connect method
+	CONNECT
+	// TRACE http request trace method
+	TRACE
 )
 
 func (m Method) String() string {
@@ -79,6 +91,20 @@ func (m Method) String() string {
 		name = "POST"
 	case GET:
 		name = "GET"
+	case PUT:
+		name = "PUT"
+	case DELETE:
+		name = "DELETE"
+	case PATCH:
+		name = "PATCH"
+	case HEAD:
+		name = "HEAD"
+	case OPTIONS:
+		name = "OPTIONS"
+	case CONNECT:
+		name = "CONNECT"
+	case TRACE:
+		name = "TRACE"
 	default:
 		name = "UNKNOWN"
 	}
@@ -86,6 +112,11 @@ func (m Method) String() string {
 	return name
 }
 
+// hasBody parameters of POST, PUT and PATCH are sent in the body, others in the query string
+func (m Method) hasBody() bool {
+	return m == POST || m == PUT || m == PATCH
+}
+
 // AbsolutePath get execute binary path
 func AbsolutePath() (string, error) {
 	file, err := exec.LookPath(os.Args[0])
@@ -155,63 +186,56 @@ type CertType int
 const (
 	// PKCS1 CertType
 	PKCS1 CertType = iota
-	// PKCS8 CertType
+	// PKCS8 CertType, PKCS #8 private keys and PKIX public keys
 	PKCS8
+	// PKIX CertType, public keys, the same as PKCS8
+	PKIX
+	// SEC1 CertType, EC private keys
+	SEC1
 )
 
-// RsaEncode rsa encode
+// RsaEncode rsa encode, PKCS #1 v1.5, b must fit in one block of the key
 func RsaEncode(b, rsaKey []byte, t ...CertType) ([]byte, error) {
-	block, _ := pem.Decode(rsaKey)
-	if block == nil {
-		return b, errors.New("key error")
-	}
-	certType := PKCS8
-	if len(t) > 0 {
-		certType = t[0]
-	}
-	var pub interface{}
-	var err error
-	switch certType {
-	case PKCS1:
-		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
-	default:
-		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
-	}
-
+	pub, err := rsaPublicKey(rsaKey, t)
 	if err != nil {
 		return b, err
 	}
-	return rsa.EncryptPKCS1v15(crand.Reader, pub.(*rsa.PublicKey), b)
+	return rsa.EncryptPKCS1v15(crand.Reader, pub, b)
 }
 
-// RsaDecode rsa decode
+// RsaDecode rsa decode, PKCS #1 v1.5, b must be one block of the key
 func RsaDecode(b, rsaKey []byte, t ...CertType) ([]byte, error) {
-	block, _ := pem.Decode(rsaKey)
-	if block == nil {
-		return b, errors.New("key error")
+	priv, err := rsaPrivateKey(rsaKey, t)
+	if err != nil {
+		return b, err
 	}
-	certType := PKCS8
-	if len(t) > 0 {
-		certType = t[0]
+	return rsa.DecryptPKCS1v15(crand.Reader, priv, b)
+}
+
+// rsaPublicKey parse a PEM rsa public key, the form is detected if t is empty
+func rsaPublicKey(rsaKey []byte, t []CertType) (*rsa.PublicKey, error) {
+	key, err := parsePublicKey(rsaKey, t)
+	if err != nil {
+		return nil, err
 	}
-	var priv *rsa.PrivateKey
-	var privTemp interface{}
-	var err error
-	switch certType {
-	case PKCS1:
-		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
-	default:
-		privTemp, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not a rsa public key")
 	}
+	return pub, nil
+}
 
+// rsaPrivateKey parse a PEM rsa private key, the form is detected if t is empty
+func rsaPrivateKey(rsaKey []byte, t []CertType) (*rsa.PrivateKey, error) {
+	key, err := parsePrivateKey(rsaKey, t)
 	if err != nil {
-		return b, err
+		return nil, err
 	}
-	if privTemp != nil {
-		priv = privTemp.(*rsa.PrivateKey)
+	priv, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not a rsa private key")
 	}
-
-	return rsa.DecryptPKCS1v15(crand.Reader, priv, b)
+	return priv, nil
 }
 
 // IsIP ip address is valid
@@ -270,6 +294,16 @@ func Base64Decode(str string) ([]byte, error) {
 	return base64.StdEncoding.DecodeString(str)
 }
 
+// URLBase64Encode url safe base64 without padding
+func URLBase64Encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// URLBase64Decode url safe base64 decode, with or without padding
+func URLBase64Decode(str string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(str, "="))
+}
+
 // RangeArray generate array
 func RangeArray(m, n int) (b []int) {
 	if m >= n || m < 0 {
@@ -284,138 +318,51 @@ func RangeArray(m, n int) (b []int) {
 	return c
 }
 
-// Authcode Discuz Authcode golang version
-// params[0] encrypt/decrypt bool true：encrypt false：decrypt, default: false
+// Authcode Discuz Authcode golang version, see AuthcodeEncode and AuthcodeDecode
+// params[0] ENCODE or DECODE, default: DECODE
 // params[1] key
 // params[2] expires time(second)
-// params[3] dynamic key length
+// params[3] dynamic key length, default: 8, 0 for none
 func Authcode(text string, params ...interface{}) (str string, err error) {
 	l := len(params)
 
 	isEncode := DECODE
-	key := "abcdefghijklmnopqrstuvwxyz0123456789"
-	expiry := 0
-	cKeyLen := 8
+	opts := AuthcodeOptions{Key: "abcdefghijklmnopqrstuvwxyz0123456789", DynamicKeyLength: 8}
+	var ok bool
 
 	if l > 0 {
-		isEncode = params[0].(AuthCodeType)
+		if isEncode, ok = params[0].(AuthCodeType); !ok {
+			return "", fmt.Errorf("authcode params[0] must be AuthCodeType, not %T", params[0])
+		}
 	}
 
 	if l > 1 {
-		key = params[1].(string)
+		if opts.Key, ok = params[1].(string); !ok {
+			return "", fmt.Errorf("authcode params[1] must be string, not %T", params[1])
+		}
 	}
 
 	if l > 2 {
-		expiry = params[2].(int)
-		if expiry < 0 {
-			expiry = 0
+		expiry, ok := params[2].(int)
+		if !ok {
+			return "", fmt.Errorf("authcode params[2] must be int, not %T", params[2])
 		}
+		opts.Expiry = time.Duration(expiry) * time.Second
 	}
 
 	if l > 3 {
-		cKeyLen = params[3].(int)
-		if cKeyLen < 0 {
-			cKeyLen = 0
+		if opts.DynamicKeyLength, ok = params[3].(int); !ok {
+			return "", fmt.Errorf("authcode params[3] must be int, not %T", params[3])
 		}
-	}
-	if cKeyLen > 32 {
-		cKeyLen = 32
-	}
-
-	timestamp := time.Now().Unix()
-
-	// md5sum key
-	mKey := Md5Sum(key)
-
-	// keyA encrypt
-	keyA := Md5Sum(mKey[0:16])
-	// keyB validate
-	keyB := Md5Sum(mKey[16:])
-	// keyC dynamic key
-	var keyC string
-	if cKeyLen > 0 {
-		if isEncode == ENCODE {
-			// encrypt generate a key
-			keyC = Md5Sum(fmt.Sprint(timestamp))[32-cKeyLen:]
-		} else {
-			// decrypt get key from header of string
-			keyC = text[0:cKeyLen]
-		}
-	}
-
-	// generate encrypt/decrypt key
-	cryptKey := keyA + Md5Sum(keyA+keyC)
-	// key length
-	keyLen := len(cryptKey)
-	if isEncode == ENCODE {
-		// The first 10 strings is expires time
-		// 10-26 strings is validator strings
-		var d int64
-		if expiry > 0 {
-			d = timestamp + int64(expiry)
+		if opts.DynamicKeyLength <= 0 {
+			opts.DynamicKeyLength = -1
 		}
-		text = fmt.Sprintf("%010d%s%s", d, Md5Sum(text + keyB)[0:16], text)
-	} else {
-		// get strings except dynamic key
-		b, e := Base64Decode(text[cKeyLen:])
-		if e != nil {
-			return "", e
-		}
-		text = string(b)
-	}
-
-	// text length
-	textLen := len(text)
-	if textLen <= 0 {
-		err = fmt.Errorf("auth [%s] textLen <= 0", text)
-		return
-	}
-
-	// keys
-	box := RangeArray(0, 256)
-	//
-	rndKey := make([]int, 0, 256)
-	cryptKeyB := []byte(cryptKey)
-	for i := 0; i < 256; i++ {
-		pos := i % keyLen
-		rndKey = append(rndKey, int(cryptKeyB[pos]))
 	}
 
-	j := 0
-	for i := 0; i < 256; i++ {
-		j = (j + box[i] + rndKey[i]) % 256
-		box[i], box[j] = box[j], box[i]
-	}
-
-	textB := []byte(text)
-	a := 0
-	j = 0
-	result := make([]byte, 0, textLen)
-	for i := 0; i < textLen; i++ {
-		a = (a + 1) % 256
-		j = (j + box[a]) % 256
-		box[a], box[j] = box[j], box[a]
-		result = append(result, byte(int(textB[i])^(box[(box[a]+box[j])%256])))
-	}
-	fmt.Println(result)
 	if isEncode == ENCODE {
-		// trim equal
-		return keyC + Base64Encode(result), nil
+		return AuthcodeEncode(text, opts)
 	}
-
-	// check expire time
-	d, e := strconv.ParseInt(string(result[0:10]), 10, 0)
-	if e != nil {
-		err = fmt.Errorf("expires time error: %s", e.Error())
-		return
-	}
-
-	if (d == 0 || d-timestamp > 0) && string(result[10:26]) == Md5Sum(string(result[26:]) + keyB)[0:16] {
-		return string(result[26:]), nil
-	}
-
-	err = fmt.Errorf("Authcode text [%s] error", text)
-	return
+	return AuthcodeDecode(text, opts)
 }
 
 // TimeFormat format time.Time
@@ -591,11 +538,12 @@ func SplitBySpaceTab(str string) []string {
 
 // HTTPRequest request
 // url request url
-// method request method post or get
+// method request method, parameters of POST, PUT and PATCH are sent in the body, others in the query string
 // args[0] type is map[string]string or string, request paramaters, \x00@ if upload file
 // args[1] type is map[string]string, request headers
 // args[2] type is bool, whether to return the result
 // args[3] type is *http.Client, custom client
+// args[4] type is RetryPolicy, retry failed requests
 func HTTPRequest(url string, method Method, args ...interface{}) (string, error) {
 	paramsMap := make(map[string]string) // request parameters
 	var paramsStr string
@@ -635,6 +583,15 @@ func HTTPRequest(url string, method Method, args ...interface{}) (string, error)
 	} else {
 		client = http.DefaultClient
 	}
+	if argsLen > 4 {
+		policy, ok := args[4].(RetryPolicy)
+		if !ok {
+			return "", errors.New("Retry policy error")
+		}
+		c := *client
+		c.Transport = &RetryTransport{Policy: policy, Transport: client.Transport}
+		client = &c
+	}
 
 	var req *http.Request
 	var err error
@@ -645,7 +602,7 @@ func HTTPRequest(url string, method Method, args ...interface{}) (string, error)
 	} else {
 		queryString = URLEncode(paramsMap)
 	}
-	if method == GET {
+	if !method.hasBody() {
 		// GET
 		if queryString != "" {
 			if strings.Index(url, "?") != -1 {
@@ -657,7 +614,7 @@ func HTTPRequest(url string, method Method, args ...interface{}) (string, error)
 			}
 		}
 
-		req, err = http.NewRequest("GET", url, nil)
+		req, err = http.NewRequest(method.String(), url, nil)
 	} else {
 		// POST
 		// whether there is upload file
@@ -672,43 +629,35 @@ func HTTPRequest(url string, method Method, args ...interface{}) (string, error)
 			}
 		}
 		if isFile {
-			bodyBuf := new(bytes.Buffer)
-			bodyWriter := multipart.NewWriter(bodyBuf)
-
+			m := NewMultipart()
 			for key, value := range paramsMap {
 				if strings.Index(value, "\x00@") == 0 {
-					value = strings.Replace(value, "\x00@", "", -1)
-					fileWriter, err := bodyWriter.CreateFormFile(key, filepath.Base(value))
-					if err != nil {
-						return "", err
-					}
-					fh, err := os.Open(value)
-					if err != nil {
-						return "", err
-					}
-					defer fh.Close()
-
-					// iocopy
-					_, err = io.Copy(fileWriter, fh)
-					if err != nil {
-						return "", err
-					}
+					m.FilePath(key, strings.Replace(value, "\x00@", "", -1))
 				} else {
-					bodyWriter.WriteField(key, value)
+					m.Field(key, value)
 				}
 			}
 
-			// Important if you do not close the multipart writer you will not have a terminating boundry
-			bodyWriter.Close()
-			contentType = bodyWriter.FormDataContentType()
-			req, err = http.NewRequest("POST", url, bodyBuf)
+			// files are streamed while the request is sent
+			body, err := m.Open()
+			if err != nil {
+				return "", err
+			}
+			contentType = m.ContentType()
+			req, err = http.NewRequest(method.String(), url, body)
+			if err != nil {
+				body.Close()
+				return "", err
+			}
+			req.ContentLength = m.Length()
+			req.GetBody = m.Open
 		} else {
 			if paramsIsStr {
-				contentType = "application/json; charset-utf-8"
-				req, err = http.NewRequest("POST", url, strings.NewReader(paramsStr))
+				contentType = "application/json; charset=utf-8"
+				req, err = http.NewRequest(method.String(), url, strings.NewReader(paramsStr))
 			} else {
 				contentType = "application/x-www-form-urlencoded; charset=utf-8"
-				req, err = http.NewRequest("POST", url, strings.NewReader(queryString))
+				req, err = http.NewRequest(method.String(), url, strings.NewReader(queryString))
 			}
 
 		}
@@ -843,59 +792,99 @@ func HMacSHA256(s, key string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-// MaxEncryptBlock rsa encode max length
-var MaxEncryptBlock = 117
+// RSAPadding padding of RSAEncrypt and RSADecrypt
+type RSAPadding int
 
-// MaxDecryptBlock rsa decode max length
-var MaxDecryptBlock = 128
+const (
+	// PKCS1v15 RSAPadding, the padding of RsaEncode and RSAEncode
+	PKCS1v15 RSAPadding = iota
+	// OAEPSHA1 RSAPadding, OAEP with SHA-1 as used by most other libraries
+	OAEPSHA1
+	// OAEPSHA256 RSAPadding, OAEP with SHA-256
+	OAEPSHA256
+)
 
-// RSAEncode rsa
+func (p RSAPadding) hash() hash.Hash {
+	if p == OAEPSHA256 {
+		return sha256.New()
+	}
+	return sha1.New()
+}
+
+// maxBlock plaintext bytes fitting in one block of a size bytes key
+func (p RSAPadding) maxBlock(size int) int {
+	if p == PKCS1v15 {
+		return size - 11
+	}
+	return size - 2*p.hash().Size() - 2
+}
+
+// RSAEncode rsa encode b of any length, PKCS #1 v1.5, see RSAEncrypt
 func RSAEncode(b, key []byte, t ...CertType) ([]byte, error) {
-	l := len(b)
-	offset := 0
+	return RSAEncrypt(b, key, PKCS1v15, t...)
+}
+
+// RSADecode rsa decode, PKCS #1 v1.5, see RSADecrypt
+func RSADecode(b, key []byte, t ...CertType) ([]byte, error) {
+	return RSADecrypt(b, key, PKCS1v15, t...)
+}
+
+// RSAEncrypt rsa encrypt b of any length with the PEM public key, b is split in blocks
+// as large as the key size and padding allow, each encrypted to one key size block
+func RSAEncrypt(b, key []byte, padding RSAPadding, t ...CertType) ([]byte, error) {
+	pub, err := rsaPublicKey(key, t)
+	if err != nil {
+		return nil, err
+	}
+	maxBlock := padding.maxBlock(pub.Size())
+	if maxBlock <= 0 {
+		return nil, errors.New("rsa key too small for the padding")
+	}
+
 	var data bytes.Buffer
-	var i int
-	for l-offset > 0 {
+	for offset := 0; offset < len(b); offset += maxBlock {
+		end := offset + maxBlock
+		if end > len(b) {
+			end = len(b)
+		}
 		var cache []byte
-		var err error
-		if l-offset > MaxEncryptBlock {
-			cache, err = RsaEncode(b[offset:offset+MaxEncryptBlock], key, t...)
+		if padding == PKCS1v15 {
+			cache, err = rsa.EncryptPKCS1v15(crand.Reader, pub, b[offset:end])
 		} else {
-			cache, err = RsaEncode(b[offset:], key, t...)
+			cache, err = rsa.EncryptOAEP(padding.hash(), crand.Reader, pub, b[offset:end], nil)
 		}
 		if err != nil {
-			log.Print("RSA Encode error: ", err)
 			return nil, err
 		}
 		data.Write(cache)
-		i++
-		offset = i * MaxEncryptBlock
 	}
 
 	return data.Bytes(), nil
 }
 
-// RSADecode rsa decode
-func RSADecode(b, key []byte, t ...CertType) ([]byte, error) {
-	l := len(b)
-	offset := 0
+// RSADecrypt rsa decrypt the blocks of b encrypted by RSAEncrypt with the PEM private key
+func RSADecrypt(b, key []byte, padding RSAPadding, t ...CertType) ([]byte, error) {
+	priv, err := rsaPrivateKey(key, t)
+	if err != nil {
+		return nil, err
+	}
+	size := priv.Size()
+	if len(b)%size != 0 {
+		return nil, fmt.Errorf("rsa data length %d is not a multiple of the key size %d", len(b), size)
+	}
+
 	var data bytes.Buffer
-	var i int
-	for l-offset > 0 {
+	for offset := 0; offset < len(b); offset += size {
 		var cache []byte
-		var err error
-		if l-offset > MaxDecryptBlock {
-			cache, err = RsaDecode(b[offset:offset+MaxDecryptBlock], key, t...)
+		if padding == PKCS1v15 {
+			cache, err = rsa.DecryptPKCS1v15(crand.Reader, priv, b[offset:offset+size])
 		} else {
-			cache, err = RsaDecode(b[offset:], key, t...)
+			cache, err = rsa.DecryptOAEP(padding.hash(), crand.Reader, priv, b[offset:offset+size], nil)
 		}
 		if err != nil {
-			log.Print("RSA Decode error: ", err)
 			return nil, err
 		}
 		data.Write(cache)
-		i++
-		offset = i * MaxDecryptBlock
 	}
 
 	return data.Bytes(), nil
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// HTTPError response with a status other than 2xx
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+// maxErrorExcerpt bytes of the body in the error message
+const maxErrorExcerpt = 256
+
+func (e *HTTPError) Error() string {
+	body := strings.TrimSpace(string(e.Body))
+	if len(body) > maxErrorExcerpt {
+		body = body[:maxErrorExcerpt] + "..."
+	}
+	if body == "" {
+		return "http " + e.Status
+	}
+	return fmt.Sprintf("http %s: %s", e.Status, body)
+}
+
+// JSON decode the error body into v, e.g. the error object of an api
+func (e *HTTPError) JSON(v interface{}) error {
+	return json.Unmarshal(e.Body, v)
+}
+
+// DoJSON send r and decode a 2xx JSON response into Resp, other statuses return *HTTPError
+//
+//	user, err := common.DoJSON[User](ctx, common.NewRequest(url).Header("Authorization", token))
+func DoJSON[Resp any](ctx context.Context, r *Request) (Resp, error) {
+	var v Resp
+	if r.header.Get("Accept") == "" {
+		r.Header("Accept", "application/json")
+	}
+	res, err := r.Do(ctx)
+	if err != nil {
+		return v, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return v, &HTTPError{StatusCode: res.StatusCode, Status: res.Status, Header: res.Header, Body: res.Body}
+	}
+	if res.StatusCode == http.StatusNoContent || len(res.Body) == 0 {
+		return v, nil
+	}
+	if contentType := res.Header.Get("Content-Type"); contentType != "" && !isJSON(contentType) {
+		return v, fmt.Errorf("http response content type is %s, not json", contentType)
+	}
+	if err := res.JSON(&v); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// GetJSON GET url and decode the JSON response into Resp
+func GetJSON[Resp any](ctx context.Context, url string) (Resp, error) {
+	return DoJSON[Resp](ctx, NewRequest(url))
+}
+
+// PostJSON POST req as JSON to url and decode the JSON response into Resp
+func PostJSON[Req, Resp any](ctx context.Context, url string, req Req) (Resp, error) {
+	return DoJSON[Resp](ctx, NewRequest(url).Method(POST).JSON(req))
+}
+
+// isJSON application/json or a +json type like application/problem+json
+func isJSON(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (t == "application/json" || strings.HasSuffix(t, "+json"))
+}
@@ -0,0 +1,198 @@
+package common
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+)
+
+// JWK JSON web key of RFC 7517
+type JWK struct {
+	KeyType   string       `json:"kty"`
+	KeyID     string       `json:"kid,omitempty"`
+	Use       string       `json:"use,omitempty"`
+	Algorithm JWTAlgorithm `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+	// oct
+	K string `json:"k,omitempty"`
+}
+
+// JWKS JSON web key set
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// JWTKeys verification keys of the set, keys of other uses or unsupported types are skipped
+func (s *JWKS) JWTKeys() ([]*JWTKey, error) {
+	keys := make([]*JWTKey, 0, len(s.Keys))
+	for _, jwk := range s.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		k, err := jwk.JWTKey()
+		if errors.Is(err, errJWKUnsupported) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: %w", jwk.KeyID, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+var errJWKUnsupported = errors.New("unsupported jwk")
+
+// JWTKey key of the JWK, its alg if set must match the key type
+func (j *JWK) JWTKey() (*JWTKey, error) {
+	var k *JWTKey
+	var key interface{}
+	switch j.KeyType {
+	case "RSA":
+		n, err := URLBase64Decode(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := URLBase64Decode(j.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("jwk exponent")
+		}
+		key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		if j.Curve != "P-256" {
+			return nil, errJWKUnsupported
+		}
+		x, err := URLBase64Decode(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := URLBase64Decode(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("jwk point is not on P-256")
+		}
+		key = pub
+	case "OKP":
+		if j.Curve != "Ed25519" {
+			return nil, errJWKUnsupported
+		}
+		x, err := URLBase64Decode(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("jwk ed25519 key")
+		}
+		key = ed25519.PublicKey(x)
+	case "oct":
+		secret, err := URLBase64Decode(j.K)
+		if err != nil {
+			return nil, err
+		}
+		k = NewHMACKey(j.KeyID, secret)
+	default:
+		return nil, errJWKUnsupported
+	}
+
+	if k == nil {
+		var err error
+		if k, err = newJWTKey(j.KeyID, key); err != nil {
+			return nil, err
+		}
+	}
+	if j.Algorithm != "" && j.Algorithm != k.Algorithm {
+		if j.Algorithm == HS256 || j.Algorithm == RS256 || j.Algorithm == ES256 || j.Algorithm == EdDSA {
+			return nil, fmt.Errorf("jwk alg %s of a %s key", j.Algorithm, j.KeyType)
+		}
+		return nil, errJWKUnsupported
+	}
+	return k, nil
+}
+
+// NewJWK public JWK of key, secrets of HS256 are not published
+func NewJWK(key *JWTKey) (*JWK, error) {
+	j := &JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+	switch pub := key.public().(type) {
+	case *rsa.PublicKey:
+		j.KeyType = "RSA"
+		j.N = URLBase64Encode(pub.N.Bytes())
+		j.E = URLBase64Encode(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 keys are on P-256")
+		}
+		j.KeyType, j.Curve = "EC", "P-256"
+		b := make([]byte, 64)
+		pub.X.FillBytes(b[:32])
+		pub.Y.FillBytes(b[32:])
+		j.X, j.Y = URLBase64Encode(b[:32]), URLBase64Encode(b[32:])
+	case ed25519.PublicKey:
+		j.KeyType, j.Curve = "OKP", "Ed25519"
+		j.X = URLBase64Encode(pub)
+	default:
+		return nil, fmt.Errorf("%s keys are not published", key.Algorithm)
+	}
+	return j, nil
+}
+
+// ParseJWKS verification keys of a JWKS document
+func ParseJWKS(b []byte) ([]*JWTKey, error) {
+	var s JWKS
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	return s.JWTKeys()
+}
+
+// LoadJWKS verification keys of the JWKS file
+func LoadJWKS(fileName string) ([]*JWTKey, error) {
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(b)
+}
+
+// FetchJWKS verification keys of the JWKS at url, e.g. https://example.com/.well-known/jwks.json
+func FetchJWKS(ctx context.Context, url string) ([]*JWTKey, error) {
+	s, err := GetJSON[JWKS](ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return s.JWTKeys()
+}
+
+// JWKSHandler serve the public keys of keys as a JWKS, to publish the keys of SignJWT
+func JWKSHandler(keys ...*JWTKey) (http.Handler, error) {
+	var s JWKS
+	for _, k := range keys {
+		j, err := NewJWK(k)
+		if err != nil {
+			return nil, err
+		}
+		s.Keys = append(s.Keys, j)
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=300")
+		w.Write(b)
+	}), nil
+}
@@ -0,0 +1,361 @@
+package common
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrJWTMalformed token is not a JWT
+	ErrJWTMalformed = errors.New("jwt malformed")
+	// ErrJWTExpired token is past its exp
+	ErrJWTExpired = errors.New("jwt expired")
+	// ErrJWTNotValidYet token is before its nbf or iat
+	ErrJWTNotValidYet = errors.New("jwt not valid yet")
+	// ErrJWTClaims iss or aud of the token are not the expected ones
+	ErrJWTClaims = errors.New("jwt claims invalid")
+	// ErrJWTKey no key of the verifier matches the kid and alg of the token
+	ErrJWTKey = errors.New("jwt key not found")
+)
+
+// JWTAlgorithm alg of a JWT
+type JWTAlgorithm string
+
+const (
+	// HS256 HMAC with SHA-256
+	HS256 JWTAlgorithm = "HS256"
+	// RS256 RSA PKCS #1 v1.5 with SHA-256
+	RS256 JWTAlgorithm = "RS256"
+	// ES256 ECDSA on P-256 with SHA-256
+	ES256 JWTAlgorithm = "ES256"
+	// EdDSA Ed25519
+	EdDSA JWTAlgorithm = "EdDSA"
+)
+
+// JWTKey key of a JWT algorithm: a secret []byte for HS256, *rsa.PrivateKey or *rsa.PublicKey for RS256,
+// *ecdsa.PrivateKey or *ecdsa.PublicKey for ES256, ed25519.PrivateKey or ed25519.PublicKey for EdDSA
+type JWTKey struct {
+	// ID kid of the key, sent in the header of the tokens it signs
+	ID        string
+	Algorithm JWTAlgorithm
+	Key       interface{}
+}
+
+// NewHMACKey HS256 key of secret
+func NewHMACKey(id string, secret []byte) *JWTKey {
+	return &JWTKey{ID: id, Algorithm: HS256, Key: secret}
+}
+
+// NewJWTKey key of a PEM private or public key, the algorithm is RS256, ES256 or EdDSA by the key
+func NewJWTKey(id string, pemKey []byte) (*JWTKey, error) {
+	_, private, err := DetectCertType(pemKey)
+	if err != nil {
+		return nil, err
+	}
+	var key interface{}
+	if private {
+		key, err = parsePrivateKey(pemKey, nil)
+	} else {
+		key, err = parsePublicKey(pemKey, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newJWTKey(id, key)
+}
+
+func newJWTKey(id string, key interface{}) (*JWTKey, error) {
+	k := &JWTKey{ID: id, Key: key}
+	switch key := key.(type) {
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		k.Algorithm = RS256
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 keys are on P-256")
+		}
+		k.Algorithm = ES256
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 keys are on P-256")
+		}
+		k.Algorithm = ES256
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		k.Algorithm = EdDSA
+	default:
+		return nil, fmt.Errorf("unsupported jwt key %T", key)
+	}
+	return k, nil
+}
+
+// public public key of k, the secret for HS256
+func (k *JWTKey) public() interface{} {
+	if signer, ok := k.Key.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return k.Key
+}
+
+func (k *JWTKey) sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	switch key := k.Key.(type) {
+	case []byte:
+		if k.Algorithm == HS256 {
+			h := hmac.New(sha256.New, key)
+			h.Write(data)
+			return h.Sum(nil), nil
+		}
+	case *rsa.PrivateKey:
+		if k.Algorithm == RS256 {
+			return rsa.SignPKCS1v15(crand.Reader, key, crypto.SHA256, digest[:])
+		}
+	case *ecdsa.PrivateKey:
+		if k.Algorithm == ES256 {
+			r, s, err := ecdsa.Sign(crand.Reader, key, digest[:])
+			if err != nil {
+				return nil, err
+			}
+			// r and s of 32 bytes each instead of ASN.1
+			sig := make([]byte, 64)
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+			return sig, nil
+		}
+	case ed25519.PrivateKey:
+		if k.Algorithm == EdDSA {
+			return ed25519.Sign(key, data), nil
+		}
+	}
+	return nil, fmt.Errorf("%T is not a %s signing key", k.Key, k.Algorithm)
+}
+
+func (k *JWTKey) verify(data, sig []byte) bool {
+	digest := sha256.Sum256(data)
+	switch key := k.public().(type) {
+	case []byte:
+		h := hmac.New(sha256.New, key)
+		h.Write(data)
+		return k.Algorithm == HS256 && hmac.Equal(h.Sum(nil), sig)
+	case *rsa.PublicKey:
+		return k.Algorithm == RS256 && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	case *ecdsa.PublicKey:
+		if k.Algorithm != ES256 || len(sig) != 64 {
+			return false
+		}
+		return ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	case ed25519.PublicKey:
+		return k.Algorithm == EdDSA && ed25519.Verify(key, data, sig)
+	}
+	return false
+}
+
+// NumericDate seconds since the epoch of exp, nbf and iat, decoded from integers or floats
+type NumericDate int64
+
+// NewNumericDate NumericDate of t
+func NewNumericDate(t time.Time) NumericDate {
+	return NumericDate(t.Unix())
+}
+
+// Time time of d
+func (d NumericDate) Time() time.Time {
+	return time.Unix(int64(d), 0)
+}
+
+// UnmarshalJSON decode integer or float seconds
+func (d *NumericDate) UnmarshalJSON(b []byte) error {
+	var f float64
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	*d = NumericDate(f)
+	return nil
+}
+
+// Audience aud of a JWT, a string or an array of strings
+type Audience []string
+
+// MarshalJSON encode a single audience as a string
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON decode a string or an array of strings
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		*a = Audience{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// Contains whether aud is one of the audiences
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisteredClaims registered claims of RFC 7519, embed it in the claims of ParseJWT
+//
+//	type UserClaims struct {
+//		common.RegisteredClaims
+//		Role string `json:"role"`
+//	}
+type RegisteredClaims struct {
+	Issuer    string      `json:"iss,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Audience  Audience    `json:"aud,omitempty"`
+	ExpiresAt NumericDate `json:"exp,omitempty"`
+	NotBefore NumericDate `json:"nbf,omitempty"`
+	IssuedAt  NumericDate `json:"iat,omitempty"`
+	ID        string      `json:"jti,omitempty"`
+}
+
+// Registered registered claims, promoted to the claims embedding RegisteredClaims
+func (c RegisteredClaims) Registered() RegisteredClaims {
+	return c
+}
+
+// JWTClaims claims of ParseJWT
+type JWTClaims interface {
+	Registered() RegisteredClaims
+}
+
+type jwtHeader struct {
+	Algorithm JWTAlgorithm `json:"alg"`
+	Type      string       `json:"typ,omitempty"`
+	KeyID     string       `json:"kid,omitempty"`
+}
+
+// SignJWT token of claims signed with key, its kid is set in the header
+func SignJWT(claims interface{}, key *JWTKey) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := URLBase64Encode(header) + "." + URLBase64Encode(payload)
+	sig, err := key.sign([]byte(signed))
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + URLBase64Encode(sig), nil
+}
+
+// JWTVerifier keys and expected claims of ParseJWT
+type JWTVerifier struct {
+	// Keys keys of the tokens, selected by kid when the token has one, or else by alg
+	Keys []*JWTKey
+	// Issuer iss of the tokens if not empty
+	Issuer string
+	// Audience audience required in aud of the tokens if not empty
+	Audience string
+	// Leeway allowed clock skew for exp, nbf and iat
+	Leeway time.Duration
+	// RequireExp reject tokens without exp
+	RequireExp bool
+	// Now current time, time.Now if nil
+	Now func() time.Time
+}
+
+// ParseJWT verify the signature and the claims of token and decode its claims into T,
+// errors are ErrJWTMalformed, ErrJWTKey, ErrSignature, ErrJWTExpired, ErrJWTNotValidYet or ErrJWTClaims
+//
+//	claims, err := common.ParseJWT[UserClaims](token, verifier)
+func ParseJWT[T JWTClaims](token string, v *JWTVerifier) (T, error) {
+	var claims T
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, ErrJWTMalformed
+	}
+	b, err := URLBase64Decode(parts[0])
+	var header jwtHeader
+	if err != nil || json.Unmarshal(b, &header) != nil {
+		return claims, ErrJWTMalformed
+	}
+	sig, err := URLBase64Decode(parts[2])
+	if err != nil {
+		return claims, ErrJWTMalformed
+	}
+
+	// the alg of the token must be the one of the key, "none" never is
+	verified := false
+	found := false
+	for _, k := range v.Keys {
+		if k.Algorithm != header.Algorithm || (header.KeyID != "" && k.ID != header.KeyID) {
+			continue
+		}
+		found = true
+		if k.verify([]byte(parts[0]+"."+parts[1]), sig) {
+			verified = true
+			break
+		}
+	}
+	if !found {
+		return claims, fmt.Errorf("%w: kid %q alg %q", ErrJWTKey, header.KeyID, header.Algorithm)
+	}
+	if !verified {
+		return claims, ErrSignature
+	}
+
+	if b, err = URLBase64Decode(parts[1]); err != nil {
+		return claims, ErrJWTMalformed
+	}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return claims, fmt.Errorf("%w: %s", ErrJWTMalformed, err)
+	}
+	// null claims of a pointer T
+	if c := reflect.ValueOf(claims); !c.IsValid() || c.Kind() == reflect.Ptr && c.IsNil() {
+		return claims, fmt.Errorf("%w: no claims", ErrJWTMalformed)
+	}
+	return claims, v.validate(claims.Registered())
+}
+
+func (v *JWTVerifier) validate(c RegisteredClaims) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	if c.ExpiresAt != 0 && !now.Before(c.ExpiresAt.Time().Add(v.Leeway)) {
+		return ErrJWTExpired
+	}
+	if c.ExpiresAt == 0 && v.RequireExp {
+		return fmt.Errorf("%w: exp missing", ErrJWTClaims)
+	}
+	if c.NotBefore != 0 && now.Add(v.Leeway).Before(c.NotBefore.Time()) {
+		return ErrJWTNotValidYet
+	}
+	if c.IssuedAt != 0 && now.Add(v.Leeway).Before(c.IssuedAt.Time()) {
+		return fmt.Errorf("%w: issued in the future", ErrJWTNotValidYet)
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return fmt.Errorf("%w: iss %q", ErrJWTClaims, c.Issuer)
+	}
+	if v.Audience != "" && !c.Audience.Contains(v.Audience) {
+		return fmt.Errorf("%w: aud %q", ErrJWTClaims, []string(c.Audience))
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package common
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// KeyType type of GenerateKey keys
+type KeyType int
+
+const (
+	// RSAKey rsa key of the given bits, 2048 by default
+	RSAKey KeyType = iota
+	// ECDSAP256Key ecdsa key on P-256
+	ECDSAP256Key
+	// ECDSAP384Key ecdsa key on P-384
+	ECDSAP384Key
+	// Ed25519Key ed25519 key
+	Ed25519Key
+)
+
+// NewKeyType key type of name: rsa, ec256, ec384 or ed25519
+func NewKeyType(name string) (KeyType, error) {
+	switch name {
+	case "rsa":
+		return RSAKey, nil
+	case "ec256", "p256", "ecdsa":
+		return ECDSAP256Key, nil
+	case "ec384", "p384":
+		return ECDSAP384Key, nil
+	case "ed25519":
+		return Ed25519Key, nil
+	}
+	return RSAKey, fmt.Errorf("unknown key type %s", name)
+}
+
+// NewCertType cert type of name: pkcs1, pkcs8, pkix or sec1
+func NewCertType(name string) (CertType, error) {
+	switch name {
+	case "pkcs1":
+		return PKCS1, nil
+	case "pkcs8":
+		return PKCS8, nil
+	case "pkix":
+		return PKIX, nil
+	case "sec1":
+		return SEC1, nil
+	}
+	return PKCS8, fmt.Errorf("unknown cert type %s", name)
+}
+
+func (t CertType) String() string {
+	switch t {
+	case PKCS1:
+		return "PKCS1"
+	case PKCS8:
+		return "PKCS8"
+	case PKIX:
+		return "PKIX"
+	case SEC1:
+		return "SEC1"
+	}
+	return "UNKNOWN"
+}
+
+// GenerateKey new key pair as PEM, a PKCS #8 private key and a PKIX public key,
+// bits is the size of rsa keys, 2048 if 0
+func GenerateKey(keyType KeyType, bits int) (privateKey, publicKey []byte, err error) {
+	var priv crypto.Signer
+	switch keyType {
+	case RSAKey:
+		if bits == 0 {
+			bits = 2048
+		}
+		priv, err = rsa.GenerateKey(crand.Reader, bits)
+	case ECDSAP256Key:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	case ECDSAP384Key:
+		priv, err = ecdsa.GenerateKey(elliptic.P384(), crand.Reader)
+	case Ed25519Key:
+		_, priv, err = ed25519.GenerateKey(crand.Reader)
+	default:
+		err = errors.New("unknown key type")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if privateKey, err = encodePrivateKey(priv, PKCS8); err != nil {
+		return nil, nil, err
+	}
+	if publicKey, err = encodePublicKey(priv.Public(), PKIX); err != nil {
+		return nil, nil, err
+	}
+	return privateKey, publicKey, nil
+}
+
+// DetectCertType form of a PEM key and whether it is a private key,
+// by the PEM type or by trying each form
+func DetectCertType(key []byte) (certType CertType, private bool, err error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return PKCS8, false, errors.New("key error")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return PKCS1, true, nil
+	case "EC PRIVATE KEY":
+		return SEC1, true, nil
+	case "PRIVATE KEY":
+		return PKCS8, true, nil
+	case "RSA PUBLIC KEY":
+		return PKCS1, false, nil
+	case "PUBLIC KEY":
+		return PKIX, false, nil
+	case "ENCRYPTED PRIVATE KEY":
+		return PKCS8, true, errors.New("encrypted private keys are not supported")
+	}
+
+	if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return PKCS8, true, nil
+	}
+	if _, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return PKCS1, true, nil
+	}
+	if _, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return SEC1, true, nil
+	}
+	if _, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return PKIX, false, nil
+	}
+	if _, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return PKCS1, false, nil
+	}
+	return PKCS8, false, fmt.Errorf("unknown key %s", block.Type)
+}
+
+// ConvertPrivateKey convert a PEM private key to certType: PKCS1 for rsa keys, SEC1 for ecdsa keys or PKCS8
+func ConvertPrivateKey(privateKey []byte, certType CertType) ([]byte, error) {
+	key, err := parsePrivateKey(privateKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	return encodePrivateKey(key, certType)
+}
+
+// ConvertPublicKey convert a PEM public key to certType: PKCS1 for rsa keys or PKIX
+func ConvertPublicKey(publicKey []byte, certType CertType) ([]byte, error) {
+	key, err := parsePublicKey(publicKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	return encodePublicKey(key, certType)
+}
+
+// PublicKey PEM public key of a PEM private key, PKIX by default
+func PublicKey(privateKey []byte, t ...CertType) ([]byte, error) {
+	key, err := parsePrivateKey(privateKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unknown private key")
+	}
+	certType := PKIX
+	if len(t) > 0 {
+		certType = t[0]
+	}
+	return encodePublicKey(signer.Public(), certType)
+}
+
+func encodePrivateKey(key crypto.PrivateKey, certType CertType) ([]byte, error) {
+	switch certType {
+	case PKCS1:
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("PKCS1 private keys are rsa keys")
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), nil
+	case SEC1:
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("SEC1 private keys are ecdsa keys")
+		}
+		b, err := x509.MarshalECPrivateKey(ecKey)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), nil
+	case PKCS8:
+		b, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}), nil
+	}
+	return nil, fmt.Errorf("%s is not a private key form", certType)
+}
+
+func encodePublicKey(key crypto.PublicKey, certType CertType) ([]byte, error) {
+	switch certType {
+	case PKCS1:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("PKCS1 public keys are rsa keys")
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(rsaKey)}), nil
+	case PKIX, PKCS8:
+		b, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}), nil
+	}
+	return nil, fmt.Errorf("%s is not a public key form", certType)
+}
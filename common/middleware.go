@@ -0,0 +1,153 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Middleware wraps the RoundTripper sending requests, e.g. to sign, log or time them.
+// Middlewares must not modify the request they get, change a clone instead.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapter to use a function as http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wrap transport with middlewares, the first middleware sees the request first,
+// transport is http.DefaultTransport if nil
+func Chain(transport http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		transport = middlewares[i](transport)
+	}
+	return transport
+}
+
+// NewClient http client sending requests through middlewares
+//
+//	client := common.NewClient(common.UserAgent("app/1.0"), common.BearerAuth(token), log.HTTPLogging(logger))
+func NewClient(middlewares ...Middleware) *http.Client {
+	return &http.Client{Transport: Chain(nil, middlewares...)}
+}
+
+// Header set header key on requests without it
+func Header(key, value string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(key) != "" {
+				return next.RoundTrip(req)
+			}
+			r := req.Clone(req.Context())
+			r.Header.Set(key, value)
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+// UserAgent set the User-Agent of requests without one
+func UserAgent(userAgent string) Middleware {
+	return Header("User-Agent", userAgent)
+}
+
+// BearerAuth set Authorization: Bearer token on requests without Authorization
+func BearerAuth(token string) Middleware {
+	return Header("Authorization", "Bearer "+token)
+}
+
+// BasicAuth set basic Authorization on requests without Authorization
+func BasicAuth(username, password string) Middleware {
+	return Header("Authorization", "Basic "+Base64Encode([]byte(username+":"+password)))
+}
+
+// Timing call fn with the result and duration of each request, e.g. to record metrics
+func Timing(fn func(req *http.Request, res *http.Response, err error, duration time.Duration)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next.RoundTrip(req)
+			fn(req, res, err, time.Since(start))
+			return res, err
+		})
+	}
+}
+
+// Retry retry requests with policy, see RetryTransport
+func Retry(policy RetryPolicy) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &RetryTransport{Policy: policy, Transport: next}
+	}
+}
+
+// RateLimit allow n requests per interval with bursts of up to n, requests wait for their turn
+// or until their context is done. Every request fails if n or per is not positive.
+func RateLimit(n int, per time.Duration) Middleware {
+	if n <= 0 || per <= 0 {
+		err := fmt.Errorf("rate limit of %d requests per %s", n, per)
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return nil, err
+			})
+		}
+	}
+	limiter := &rateLimiter{tokens: float64(n), max: float64(n), rate: float64(n) / float64(per), last: time.Now()}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if err := limiter.wait(req.Context()); err != nil {
+				return nil, err
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// rateLimiter token bucket
+type rateLimiter struct {
+	lock   sync.Mutex
+	tokens float64
+	max    float64
+	// rate tokens per nanosecond
+	rate float64
+	last time.Time
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.lock.Lock()
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.last)) * l.rate
+	if l.tokens > l.max {
+		l.tokens = l.max
+	}
+	l.last = now
+	// take the token now, waiting for it if the bucket is empty
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate)
+	}
+	l.lock.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		// give the token back
+		l.lock.Lock()
+		l.tokens++
+		l.lock.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,231 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// FilePart file part of a multipart body
+type FilePart struct {
+	Field    string
+	FileName string
+	// ContentType application/octet-stream if empty
+	ContentType string
+	// Open opens the content for each send, so retries can replay the body
+	Open func() (io.ReadCloser, error)
+	// Reader content used when Open is nil, it can be sent only once
+	Reader io.Reader
+	// Size content length, unknown if 0 and SizeKnown is false,
+	// then the body is sent without Content-Length
+	Size int64
+	// SizeKnown Size 0 is an empty content
+	SizeKnown bool
+}
+
+// Multipart streaming multipart/form-data body, files are read while the body is sent
+// instead of being buffered in memory
+//
+//	m := common.NewMultipart().Field("name", "report").FilePath("file", "/data/report.csv")
+//	res, err := common.NewRequest(url).Method(common.POST).Multipart(m).Do(ctx)
+type Multipart struct {
+	fields   [][2]string
+	files    []FilePart
+	boundary string
+	progress func(sent, total int64)
+	err      error
+	// sent 1 once a body with file part readers was opened
+	sent int32
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// NewMultipart new multipart body with a random boundary
+func NewMultipart() *Multipart {
+	return &Multipart{boundary: multipart.NewWriter(ioutil.Discard).Boundary()}
+}
+
+// Field add a form field, fields are sent before files
+func (m *Multipart) Field(key, value string) *Multipart {
+	m.fields = append(m.fields, [2]string{key, value})
+	return m
+}
+
+// File add a file part
+func (m *Multipart) File(part FilePart) *Multipart {
+	m.files = append(m.files, part)
+	return m
+}
+
+// FilePath add the file at path, it is opened when the body is sent
+func (m *Multipart) FilePath(field, path string) *Multipart {
+	info, err := os.Stat(path)
+	if err != nil {
+		if m.err == nil {
+			m.err = err
+		}
+		return m
+	}
+	return m.File(FilePart{
+		Field:     field,
+		FileName:  filepath.Base(path),
+		Open:      func() (io.ReadCloser, error) { return os.Open(path) },
+		Size:      info.Size(),
+		SizeKnown: true,
+	})
+}
+
+// Progress call fn after each write of the body, total is -1 if unknown
+func (m *Multipart) Progress(fn func(sent, total int64)) *Multipart {
+	m.progress = fn
+	return m
+}
+
+// ContentType multipart/form-data with the boundary
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// Length body length, -1 if the size of a file is unknown
+func (m *Multipart) Length() int64 {
+	var cw countWriter
+	w := multipart.NewWriter(&cw)
+	w.SetBoundary(m.boundary)
+	for _, f := range m.fields {
+		w.WriteField(f[0], f[1])
+	}
+	for _, f := range m.files {
+		if !f.sizeKnown() {
+			return -1
+		}
+		w.CreatePart(f.header())
+		cw.n += f.Size
+	}
+	w.Close()
+	return cw.n
+}
+
+// replayable whether Open can be called more than once
+func (m *Multipart) replayable() bool {
+	for _, f := range m.files {
+		if f.Open == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Open body reader, the body is written by a goroutine through a pipe,
+// closing the reader stops it and closes the open file
+func (m *Multipart) Open() (io.ReadCloser, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	for _, f := range m.files {
+		if f.Open == nil && f.Reader == nil {
+			return nil, fmt.Errorf("file part %s has no content", f.Field)
+		}
+	}
+	// a second send would find the readers drained
+	if !m.replayable() && !atomic.CompareAndSwapInt32(&m.sent, 0, 1) {
+		return nil, errors.New("multipart body with file part readers already sent")
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		var out io.Writer = pw
+		if m.progress != nil {
+			out = &progressWriter{w: pw, total: m.Length(), fn: m.progress}
+		}
+		pw.CloseWithError(m.write(out))
+	}()
+	return pr, nil
+}
+
+func (m *Multipart) write(out io.Writer) error {
+	w := multipart.NewWriter(out)
+	w.SetBoundary(m.boundary)
+	for _, f := range m.fields {
+		if err := w.WriteField(f[0], f[1]); err != nil {
+			return err
+		}
+	}
+	for i := range m.files {
+		if err := m.files[i].write(w); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func (f *FilePart) write(w *multipart.Writer) error {
+	part, err := w.CreatePart(f.header())
+	if err != nil {
+		return err
+	}
+
+	r := f.Reader
+	if f.Open != nil {
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		r = rc
+	}
+
+	n, err := io.Copy(part, r)
+	if err != nil {
+		return err
+	}
+	if f.sizeKnown() && n != f.Size {
+		return fmt.Errorf("file part %s: read %d bytes, size is %d", f.Field, n, f.Size)
+	}
+	return nil
+}
+
+func (f *FilePart) sizeKnown() bool {
+	return f.Size > 0 || f.SizeKnown && f.Size == 0
+}
+
+func (f *FilePart) header() textproto.MIMEHeader {
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(f.Field), quoteEscaper.Replace(f.FileName)))
+	h.Set("Content-Type", contentType)
+	return h
+}
+
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+type progressWriter struct {
+	w     io.Writer
+	sent  int64
+	total int64
+	fn    func(sent, total int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.sent += int64(n)
+	w.fn(w.sent, w.total)
+	return n, err
+}
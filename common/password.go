@@ -0,0 +1,240 @@
+package common
+
+import (
+	crand "crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrPassword password does not match the hash
+var ErrPassword = errors.New("password mismatch")
+
+// PasswordAlgorithm algorithm of new password hashes
+type PasswordAlgorithm int
+
+const (
+	// Argon2id argon2id as $argon2id$v=19$m=65536,t=3,p=4$salt$hash
+	Argon2id PasswordAlgorithm = iota
+	// Scrypt scrypt as $scrypt$ln=15,r=8,p=1$salt$hash
+	Scrypt
+	// Bcrypt bcrypt as $2a$12$...
+	Bcrypt
+)
+
+const (
+	passwordSaltSize = 16
+	passwordKeySize  = 32
+	// limits of scrypt parameters read from hashes
+	scryptMaxLogN   = 20
+	scryptMaxR      = 32
+	scryptMaxP      = 16
+	scryptMaxMemory = 256 << 20
+)
+
+// PasswordHasher algorithm and parameters of new password hashes, hashes keep their own
+// parameters so they can be changed at any time, see NeedsRehash
+type PasswordHasher struct {
+	Algorithm PasswordAlgorithm
+	// Argon2Time iterations of argon2id
+	Argon2Time uint32
+	// Argon2Memory memory of argon2id in KiB
+	Argon2Memory uint32
+	// Argon2Threads parallelism of argon2id
+	Argon2Threads uint8
+	// ScryptLogN log2 of the cost N of scrypt
+	ScryptLogN uint8
+	// ScryptR block size of scrypt
+	ScryptR int
+	// ScryptP parallelism of scrypt
+	ScryptP int
+	// BcryptCost cost of bcrypt
+	BcryptCost int
+}
+
+// DefaultPasswordHasher argon2id with the parameters recommended by RFC 9106 for 64 MiB of memory
+var DefaultPasswordHasher = &PasswordHasher{
+	Algorithm:     Argon2id,
+	Argon2Time:    argon2Time,
+	Argon2Memory:  argon2Memory,
+	Argon2Threads: argon2Threads,
+	ScryptLogN:    15,
+	ScryptR:       8,
+	ScryptP:       1,
+	BcryptCost:    12,
+}
+
+// HashPassword hash password with DefaultPasswordHasher
+func HashPassword(password string) (string, error) {
+	return DefaultPasswordHasher.Hash(password)
+}
+
+// NeedsRehash whether hash should be replaced by a hash of DefaultPasswordHasher
+func NeedsRehash(hash string) bool {
+	return DefaultPasswordHasher.NeedsRehash(hash)
+}
+
+// Hash hash password with a random salt
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.Algorithm == Bcrypt {
+		b, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		return string(b), err
+	}
+
+	salt := make([]byte, passwordSaltSize)
+	if _, err := crand.Read(salt); err != nil {
+		return "", err
+	}
+	p := &passwordHash{algorithm: h.Algorithm, salt: salt,
+		time: h.Argon2Time, memory: h.Argon2Memory, threads: h.Argon2Threads,
+		logN: h.ScryptLogN, r: h.ScryptR, p: h.ScryptP}
+	key, err := p.derive(password, passwordKeySize)
+	if err != nil {
+		return "", err
+	}
+	p.key = key
+	return p.String(), nil
+}
+
+// NeedsRehash whether hash is a legacy hash, another algorithm or other parameters than h,
+// to hash the password again after it is verified on login
+func (h *PasswordHasher) NeedsRehash(hash string) bool {
+	if isBcrypt(hash) {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return h.Algorithm != Bcrypt || err != nil || cost != h.BcryptCost
+	}
+	p, err := parsePasswordHash(hash)
+	if err != nil || p.algorithm != h.Algorithm {
+		return true
+	}
+	if p.algorithm == Argon2id {
+		return p.time != h.Argon2Time || p.memory != h.Argon2Memory || p.threads != h.Argon2Threads
+	}
+	return p.logN != h.ScryptLogN || p.r != h.ScryptR || p.p != h.ScryptP
+}
+
+// VerifyPassword check password against hash of HashPassword, ErrPassword if it does not match.
+// Unsalted legacy hashes of Md5Sum and SHA256, 32 or 64 hex characters, are also accepted,
+// NeedsRehash reports them
+func VerifyPassword(password, hash string) error {
+	if isBcrypt(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPassword
+		}
+		return err
+	}
+	if !strings.HasPrefix(hash, "$") {
+		return verifyLegacyPassword(password, hash)
+	}
+
+	p, err := parsePasswordHash(hash)
+	if err != nil {
+		return err
+	}
+	key, err := p.derive(password, len(p.key))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(key, p.key) != 1 {
+		return ErrPassword
+	}
+	return nil
+}
+
+func verifyLegacyPassword(password, hash string) error {
+	var sum string
+	switch len(hash) {
+	case 32:
+		sum = Md5Sum(password)
+	case 64:
+		sum = SHA256(password)
+	default:
+		return errors.New("unknown password hash")
+	}
+	if subtle.ConstantTimeCompare([]byte(sum), []byte(strings.ToLower(hash))) != 1 {
+		return ErrPassword
+	}
+	return nil
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// passwordHash argon2id or scrypt hash in the PHC string format
+type passwordHash struct {
+	algorithm PasswordAlgorithm
+	salt      []byte
+	key       []byte
+	// argon2id
+	time    uint32
+	memory  uint32
+	threads uint8
+	// scrypt
+	logN uint8
+	r, p int
+}
+
+func (p *passwordHash) derive(password string, size int) ([]byte, error) {
+	if p.algorithm == Argon2id {
+		if err := checkArgon2(p.time, p.memory, p.threads); err != nil {
+			return nil, err
+		}
+		return argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(size)), nil
+	}
+	// scrypt needs 128 * r * N bytes of memory
+	if p.logN == 0 || p.logN > scryptMaxLogN || p.r < 1 || p.r > scryptMaxR || p.p < 1 || p.p > scryptMaxP ||
+		128*p.r<<p.logN > scryptMaxMemory {
+		return nil, fmt.Errorf("scrypt parameters ln=%d r=%d p=%d out of range", p.logN, p.r, p.p)
+	}
+	return scrypt.Key([]byte(password), p.salt, 1<<p.logN, p.r, p.p, size)
+}
+
+func (p *passwordHash) String() string {
+	salt, key := base64.RawStdEncoding.EncodeToString(p.salt), base64.RawStdEncoding.EncodeToString(p.key)
+	if p.algorithm == Argon2id {
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.time, p.threads, salt, key)
+	}
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s", p.logN, p.r, p.p, salt, key)
+}
+
+func parsePasswordHash(hash string) (*passwordHash, error) {
+	parts := strings.Split(hash, "$")
+	p := &passwordHash{}
+	var params string
+	var err error
+	switch {
+	case len(parts) == 6 && parts[1] == "argon2id":
+		var version int
+		if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return nil, fmt.Errorf("unsupported argon2id version %s", parts[2])
+		}
+		p.algorithm, params = Argon2id, parts[3]
+		_, err = fmt.Sscanf(params, "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads)
+		parts = parts[4:]
+	case len(parts) == 5 && parts[1] == "scrypt":
+		p.algorithm, params = Scrypt, parts[2]
+		_, err = fmt.Sscanf(params, "ln=%d,r=%d,p=%d", &p.logN, &p.r, &p.p)
+		parts = parts[3:]
+	default:
+		return nil, errors.New("unknown password hash")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("password hash parameters %s", params)
+	}
+
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[0]); err != nil {
+		return nil, fmt.Errorf("password hash salt: %w", err)
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil || len(p.key) < 16 || len(p.key) > 64 {
+		return nil, errors.New("password hash key")
+	}
+	return p, nil
+}
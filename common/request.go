@@ -0,0 +1,261 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	httpurl "net/url"
+	"strings"
+	"time"
+)
+
+// DefaultMaxResponseSize response body limit of requests without MaxSize
+const DefaultMaxResponseSize = 32 << 20
+
+// ErrResponseTooLarge the response body is larger than the MaxSize of the request
+var ErrResponseTooLarge = errors.New("http response too large")
+
+// Request http request builder
+//
+//	res, err := common.NewRequest(url).Method(common.PUT).Query("id", "1").
+//		Header("X-Token", token).JSON(data).Timeout(5 * time.Second).Do(ctx)
+type Request struct {
+	url        string
+	method     Method
+	query      httpurl.Values
+	header     http.Header
+	form       httpurl.Values
+	body       func() (io.ReadCloser, error)
+	bodyLength int64
+	// bodyOnce body cannot be replayed
+	bodyOnce    bool
+	contentType string
+	client      *http.Client
+	retry       *RetryPolicy
+	middlewares []Middleware
+	maxSize     int64
+	timeout     time.Duration
+	err         error
+}
+
+// Response http response with the whole body
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+// String body as string
+func (r *Response) String() string {
+	return string(r.Body)
+}
+
+// JSON decode body into v
+func (r *Response) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// NewRequest new request builder, method is GET by default
+func NewRequest(url string) *Request {
+	return &Request{url: url, method: GET, query: httpurl.Values{}, header: http.Header{}}
+}
+
+// Method set request method
+func (r *Request) Method(method Method) *Request {
+	r.method = method
+	return r
+}
+
+// Query add a query string parameter
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// Header set a request header
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// Form add a parameter of an application/x-www-form-urlencoded body
+func (r *Request) Form(key, value string) *Request {
+	if r.form == nil {
+		r.form = httpurl.Values{}
+	}
+	r.form.Add(key, value)
+	return r
+}
+
+// JSON send v encoded as JSON
+func (r *Request) JSON(v interface{}) *Request {
+	b, err := json.Marshal(v)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	return r.Body(b, "application/json; charset=utf-8")
+}
+
+// Body send b with contentType
+func (r *Request) Body(b []byte, contentType string) *Request {
+	r.body = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	r.bodyLength = int64(len(b))
+	r.bodyOnce = false
+	r.contentType = contentType
+	return r
+}
+
+// Multipart send the streaming multipart body m
+func (r *Request) Multipart(m *Multipart) *Request {
+	r.body = m.Open
+	r.bodyLength = m.Length()
+	r.bodyOnce = !m.replayable()
+	r.contentType = m.ContentType()
+	return r
+}
+
+// Timeout limit the whole request including reading the body
+func (r *Request) Timeout(timeout time.Duration) *Request {
+	r.timeout = timeout
+	return r
+}
+
+// Client send with client instead of http.DefaultClient
+func (r *Request) Client(client *http.Client) *Request {
+	r.client = client
+	return r
+}
+
+// MaxSize limit the response body to n bytes, DefaultMaxResponseSize by default,
+// a larger body returns ErrResponseTooLarge
+func (r *Request) MaxSize(n int64) *Request {
+	r.maxSize = n
+	return r
+}
+
+// Retry retry failed attempts with policy, see RetryTransport
+func (r *Request) Retry(policy RetryPolicy) *Request {
+	r.retry = &policy
+	return r
+}
+
+// Use send the request through middlewares, they wrap the transport of the client
+// outside of Retry so they see each request once
+func (r *Request) Use(middlewares ...Middleware) *Request {
+	r.middlewares = append(r.middlewares, middlewares...)
+	return r
+}
+
+// Build build the *http.Request, the body can be read again through GetBody
+func (r *Request) Build(ctx context.Context) (*http.Request, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	url := r.url
+	if len(r.query) > 0 {
+		if strings.Contains(url, "?") {
+			url += "&" + r.query.Encode()
+		} else {
+			url += "?" + r.query.Encode()
+		}
+	}
+
+	body, length, contentType := r.body, r.bodyLength, r.contentType
+	if body == nil && r.form != nil {
+		b := []byte(r.form.Encode())
+		body = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		}
+		length, contentType = int64(len(b)), "application/x-www-form-urlencoded; charset=utf-8"
+	}
+
+	var reader io.Reader
+	if body != nil {
+		rc, err := body()
+		if err != nil {
+			return nil, err
+		}
+		reader = rc
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method.String(), url, reader)
+	if err != nil {
+		if rc, ok := reader.(io.Closer); ok {
+			rc.Close()
+		}
+		return nil, err
+	}
+	if body != nil {
+		if !r.bodyOnce {
+			req.GetBody = body
+		}
+		req.ContentLength = length
+		if length == 0 {
+			req.Body.Close()
+			req.Body = http.NoBody
+		}
+	}
+
+	for k, v := range r.header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	return req, nil
+}
+
+// Do send the request and read the whole response, responses of any status are returned
+func (r *Request) Do(ctx context.Context) (*Response, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	req, err := r.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client := r.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if r.retry != nil || len(r.middlewares) > 0 {
+		c := *client
+		if r.retry != nil {
+			c.Transport = &RetryTransport{Policy: *r.retry, Transport: c.Transport}
+		}
+		c.Transport = Chain(c.Transport, r.middlewares...)
+		client = &c
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	maxSize := r.maxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxResponseSize
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > maxSize {
+		return nil, ErrResponseTooLarge
+	}
+
+	return &Response{StatusCode: res.StatusCode, Status: res.Status, Header: res.Header, Body: b}, nil
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retry policy of http requests
+type RetryPolicy struct {
+	// MaxAttempts attempts including the first one, 1 or less disables retries
+	MaxAttempts int
+	// MinBackoff backoff before the second attempt, doubled for each later attempt
+	MinBackoff time.Duration
+	// MaxBackoff upper bound of backoffs, a longer Retry-After returns the response instead of waiting
+	MaxBackoff time.Duration
+	// AllMethods retry POST and PATCH too, only when the server can deduplicate them.
+	// Requests with an Idempotency-Key header are always retried.
+	AllMethods bool
+}
+
+// DefaultRetryPolicy 3 attempts, backoff from 100ms up to 5s
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, MinBackoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// RetryTransport http.RoundTripper retrying connection errors and 429, 502, 503 and 504 responses
+// with exponential backoff and jitter. Request bodies are replayed through GetBody,
+// requests with a body but no GetBody are sent once.
+//
+//	client := &http.Client{Transport: &common.RetryTransport{Policy: common.DefaultRetryPolicy}}
+type RetryTransport struct {
+	Policy RetryPolicy
+	// Transport sends each attempt, http.DefaultTransport if nil
+	Transport http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	attempts := t.Policy.MaxAttempts
+	if !t.Policy.retryable(req) {
+		attempts = 1
+	}
+
+	r := req
+	for attempt := 1; ; attempt++ {
+		res, err := transport.RoundTrip(r)
+		if attempt >= attempts || req.Context().Err() != nil || !retryableResponse(res, err) {
+			return res, err
+		}
+
+		wait := t.Policy.backoff(attempt)
+		if res != nil {
+			if after, ok := retryAfter(res); ok {
+				if t.Policy.MaxBackoff > 0 && after > t.Policy.MaxBackoff {
+					return res, err
+				}
+				wait = after
+			}
+			// drain so the connection can be reused
+			io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		r = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// retryable whether req may be sent again
+func (p RetryPolicy) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return p.AllMethods || req.Header.Get("Idempotency-Key") != ""
+}
+
+// backoff wait after attempt, a random duration between half and all of MinBackoff * 2^(attempt-1)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func retryableResponse(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter Retry-After header in seconds or as an http date
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
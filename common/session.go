@@ -0,0 +1,115 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	httpurl "net/url"
+	"time"
+)
+
+// Session requests sharing a cookie jar, default headers, a base url and a rate limit,
+// e.g. to log in once and call the pages behind the login
+//
+//	s, err := common.NewSession("https://admin.example.com/")
+//	s.Header("User-Agent", "ops/1.0").RateLimit(5, time.Second)
+//	s.PostForm(ctx, "login", map[string]string{"user": user, "password": password})
+//	res, err := s.Get(ctx, "orders?page=2")
+type Session struct {
+	base        *httpurl.URL
+	header      http.Header
+	jar         *CookieJar
+	middlewares []Middleware
+	client      *http.Client
+}
+
+// NewSession new session resolving request urls against baseURL
+func NewSession(baseURL string) (*Session, error) {
+	base, err := httpurl.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{base: base, header: http.Header{}, jar: NewCookieJar()}
+	s.buildClient()
+	return s, nil
+}
+
+func (s *Session) buildClient() {
+	s.client = &http.Client{Jar: s.jar, Transport: Chain(nil, s.middlewares...)}
+}
+
+// Header set a header sent with every request of the session
+func (s *Session) Header(key, value string) *Session {
+	s.header.Set(key, value)
+	return s
+}
+
+// Use send the requests of the session through middlewares
+func (s *Session) Use(middlewares ...Middleware) *Session {
+	s.middlewares = append(s.middlewares, middlewares...)
+	s.buildClient()
+	return s
+}
+
+// RateLimit allow n requests of the session per interval, see RateLimit
+func (s *Session) RateLimit(n int, per time.Duration) *Session {
+	return s.Use(RateLimit(n, per))
+}
+
+// Jar cookie jar of the session
+func (s *Session) Jar() *CookieJar {
+	return s.jar
+}
+
+// LoadCookies add the cookies saved to fileName, a missing file is not an error
+func (s *Session) LoadCookies(fileName string) error {
+	return s.jar.Load(fileName)
+}
+
+// SaveCookies save the cookies of the session to fileName
+func (s *Session) SaveCookies(fileName string) error {
+	return s.jar.Save(fileName)
+}
+
+// Client http client of the session with its cookie jar and middlewares
+func (s *Session) Client() *http.Client {
+	return s.client
+}
+
+// URL resolve path against the base url, absolute urls are kept
+func (s *Session) URL(path string) (string, error) {
+	u, err := httpurl.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	return s.base.ResolveReference(u).String(), nil
+}
+
+// Request new request of path with the session headers and client
+func (s *Session) Request(path string) *Request {
+	url, err := s.URL(path)
+	r := NewRequest(url).Client(s.client)
+	r.err = err
+	for k, v := range s.header {
+		r.header[k] = append([]string(nil), v...)
+	}
+	return r
+}
+
+// Get GET path
+func (s *Session) Get(ctx context.Context, path string) (*Response, error) {
+	return s.Request(path).Do(ctx)
+}
+
+// PostForm POST params to path as a form
+func (s *Session) PostForm(ctx context.Context, path string, params map[string]string) (*Response, error) {
+	r := s.Request(path).Method(POST)
+	for k, v := range params {
+		r.Form(k, v)
+	}
+	return r.Do(ctx)
+}
+
+// PostJSON POST v to path as JSON
+func (s *Session) PostJSON(ctx context.Context, path string, v interface{}) (*Response, error) {
+	return s.Request(path).Method(POST).JSON(v).Do(ctx)
+}
@@ -0,0 +1,217 @@
+package common
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/md5"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// SignAlgorithm signature algorithm of Signer
+type SignAlgorithm int
+
+const (
+	// SignMD5 md5 of the string and the key, hex
+	SignMD5 SignAlgorithm = iota
+	// SignSHA256 sha256 of the string and the key, hex
+	SignSHA256
+	// SignHMACSHA256 hmac sha256 of the string with the key, hex
+	SignHMACSHA256
+	// SignRSASHA256 RSA PKCS #1 v1.5 signature of the sha256 of the string, base64
+	SignRSASHA256
+)
+
+// KeyPlacement where the key goes in the string signed by SignMD5 and SignSHA256
+type KeyPlacement int
+
+const (
+	// KeyParam append the key as a parameter, a=1&b=2&key=secret
+	KeyParam KeyPlacement = iota
+	// KeySuffix append the key, a=1&b=2secret
+	KeySuffix
+	// KeyWrap key before and after, secreta=1&b=2secret
+	KeyWrap
+)
+
+// ErrSignature signature does not match
+var ErrSignature = errors.New("signature mismatch")
+
+// Signer canonical parameter signer of partner apis: parameters sorted by name and joined
+// as a=1&b=2, empty values and the signature parameter skipped, then signed with Algorithm
+//
+//	signer := &common.Signer{Key: key, KeyPlacement: common.KeyParam, Upper: true}
+//	params["sign"], err = signer.Sign(params)
+type Signer struct {
+	Algorithm SignAlgorithm
+	// Key secret of the digest algorithms, PEM private key of SignRSASHA256
+	Key string
+	// PublicKey PEM public key verifying SignRSASHA256 signatures
+	PublicKey string
+	// KeyPlacement key placement of SignMD5 and SignSHA256
+	KeyPlacement KeyPlacement
+	// KeyName parameter name of KeyParam, "key" if empty
+	KeyName string
+	// SignField parameter holding the signature, "sign" if empty
+	SignField string
+	// Exclude other parameters not signed, e.g. sign_type
+	Exclude []string
+	// Joiner between parameters, "&" if empty
+	Joiner string
+	// Separator between name and value, "=" if empty
+	Separator string
+	// KeepEmpty sign empty values too
+	KeepEmpty bool
+	// Upper upper case hex signatures
+	Upper bool
+}
+
+func (s *Signer) signField() string {
+	if s.SignField == "" {
+		return "sign"
+	}
+	return s.SignField
+}
+
+func (s *Signer) joiner() string {
+	if s.Joiner == "" {
+		return "&"
+	}
+	return s.Joiner
+}
+
+func (s *Signer) separator() string {
+	if s.Separator == "" {
+		return "="
+	}
+	return s.Separator
+}
+
+// String canonical string of params without the key
+func (s *Signer) String(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k == s.signField() || (v == "" && !s.KeepEmpty) || Contains(s.Exclude, k) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(s.joiner())
+		}
+		b.WriteString(k)
+		b.WriteString(s.separator())
+		b.WriteString(params[k])
+	}
+	return b.String()
+}
+
+// Sign signature of params
+func (s *Signer) Sign(params map[string]string) (string, error) {
+	str := s.String(params)
+	switch s.Algorithm {
+	case SignMD5:
+		sum := md5.Sum([]byte(s.withKey(str)))
+		return s.hex(sum[:]), nil
+	case SignSHA256:
+		sum := sha256.Sum256([]byte(s.withKey(str)))
+		return s.hex(sum[:]), nil
+	case SignHMACSHA256:
+		h := hmac.New(sha256.New, []byte(s.Key))
+		h.Write([]byte(str))
+		return s.hex(h.Sum(nil)), nil
+	case SignRSASHA256:
+		key, err := rsaPrivateKey([]byte(s.Key), nil)
+		if err != nil {
+			return "", err
+		}
+		digest := sha256.Sum256([]byte(str))
+		sig, err := rsa.SignPKCS1v15(crand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(sig), nil
+	}
+	return "", errors.New("unknown sign algorithm")
+}
+
+// Verify check the signature in the SignField parameter of params, hex signatures of any case match
+func (s *Signer) Verify(params map[string]string) error {
+	sign := params[s.signField()]
+	if sign == "" {
+		return errors.New("no signature")
+	}
+
+	if s.Algorithm == SignRSASHA256 {
+		key, err := rsaPublicKey([]byte(s.PublicKey), nil)
+		if err != nil {
+			return err
+		}
+		sig, err := base64.StdEncoding.DecodeString(sign)
+		if err != nil {
+			return ErrSignature
+		}
+		digest := sha256.Sum256([]byte(s.String(params)))
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+			return ErrSignature
+		}
+		return nil
+	}
+
+	expected, err := s.Sign(params)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(strings.ToLower(expected)), []byte(strings.ToLower(sign))) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// VerifyRequest check the signature of a signed callback, parameters are read from
+// the query string and a form body, the first value of each is signed
+func (s *Signer) VerifyRequest(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	params := make(map[string]string, len(r.Form))
+	for k, v := range r.Form {
+		params[k] = v[0]
+	}
+	return s.Verify(params)
+}
+
+func (s *Signer) withKey(str string) string {
+	switch s.KeyPlacement {
+	case KeySuffix:
+		return str + s.Key
+	case KeyWrap:
+		return s.Key + str + s.Key
+	}
+	name := s.KeyName
+	if name == "" {
+		name = "key"
+	}
+	if str == "" {
+		return name + s.separator() + s.Key
+	}
+	return str + s.joiner() + name + s.separator() + s.Key
+}
+
+func (s *Signer) hex(b []byte) string {
+	if s.Upper {
+		return strings.ToUpper(hex.EncodeToString(b))
+	}
+	return hex.EncodeToString(b)
+}
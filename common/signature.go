@@ -0,0 +1,237 @@
+package common
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// SignatureAlgorithm algorithm of Sign and Verify
+type SignatureAlgorithm int
+
+const (
+	// RSASHA256 RSA PKCS #1 v1.5 with SHA-256, SHA256withRSA
+	RSASHA256 SignatureAlgorithm = iota
+	// RSASHA1 RSA PKCS #1 v1.5 with SHA-1, SHA1withRSA of older apis
+	RSASHA1
+	// RSAPSSSHA256 RSA PSS with SHA-256, salt as long as the hash
+	RSAPSSSHA256
+	// ECDSASHA256 ECDSA with SHA-256 for P-256 keys, ASN.1 signatures
+	ECDSASHA256
+	// ECDSASHA384 ECDSA with SHA-384 for P-384 keys, ASN.1 signatures
+	ECDSASHA384
+	// Ed25519 Ed25519 of the data itself
+	Ed25519
+)
+
+// curve curve of the ECDSA keys of the algorithm
+func (a SignatureAlgorithm) curve() elliptic.Curve {
+	if a == ECDSASHA384 {
+		return elliptic.P384()
+	}
+	return elliptic.P256()
+}
+
+// digest hash of data for the algorithm, nil for Ed25519
+func (a SignatureAlgorithm) digest(data []byte) (crypto.Hash, []byte) {
+	var h hash.Hash
+	var hashType crypto.Hash
+	switch a {
+	case RSASHA1:
+		h, hashType = sha1.New(), crypto.SHA1
+	case ECDSASHA384:
+		h, hashType = sha512.New384(), crypto.SHA384
+	case Ed25519:
+		return 0, nil
+	default:
+		h, hashType = sha256.New(), crypto.SHA256
+	}
+	h.Write(data)
+	return hashType, h.Sum(nil)
+}
+
+// Sign sign data with the PEM private key, its form is detected if t is not given
+func Sign(data, privateKey []byte, algorithm SignatureAlgorithm, t ...CertType) ([]byte, error) {
+	key, err := parsePrivateKey(privateKey, t)
+	if err != nil {
+		return nil, err
+	}
+	hashType, digest := algorithm.digest(data)
+
+	switch algorithm {
+	case RSASHA256, RSASHA1, RSAPSSSHA256:
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("not a rsa private key")
+		}
+		if algorithm == RSAPSSSHA256 {
+			return rsa.SignPSS(crand.Reader, priv, hashType, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.SignPKCS1v15(crand.Reader, priv, hashType, digest)
+	case ECDSASHA256, ECDSASHA384:
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an ecdsa private key")
+		}
+		if priv.Curve != algorithm.curve() {
+			return nil, fmt.Errorf("ecdsa key is not on %s", algorithm.curve().Params().Name)
+		}
+		return ecdsa.SignASN1(crand.Reader, priv, digest)
+	case Ed25519:
+		priv, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an ed25519 private key")
+		}
+		return ed25519.Sign(priv, data), nil
+	}
+	return nil, errors.New("unknown signature algorithm")
+}
+
+// Verify check the signature of data with the PEM public key, its form is detected if t is not given,
+// ErrSignature if it does not match
+func Verify(data, signature, publicKey []byte, algorithm SignatureAlgorithm, t ...CertType) error {
+	key, err := parsePublicKey(publicKey, t)
+	if err != nil {
+		return err
+	}
+	hashType, digest := algorithm.digest(data)
+
+	var ok bool
+	switch algorithm {
+	case RSASHA256, RSASHA1, RSAPSSSHA256:
+		pub, isRSA := key.(*rsa.PublicKey)
+		if !isRSA {
+			return errors.New("not a rsa public key")
+		}
+		if algorithm == RSAPSSSHA256 {
+			ok = rsa.VerifyPSS(pub, hashType, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) == nil
+		} else {
+			ok = rsa.VerifyPKCS1v15(pub, hashType, digest, signature) == nil
+		}
+	case ECDSASHA256, ECDSASHA384:
+		pub, isECDSA := key.(*ecdsa.PublicKey)
+		if !isECDSA {
+			return errors.New("not an ecdsa public key")
+		}
+		if pub.Curve != algorithm.curve() {
+			return fmt.Errorf("ecdsa key is not on %s", algorithm.curve().Params().Name)
+		}
+		ok = ecdsa.VerifyASN1(pub, digest, signature)
+	case Ed25519:
+		pub, isEd25519 := key.(ed25519.PublicKey)
+		if !isEd25519 {
+			return errors.New("not an ed25519 public key")
+		}
+		ok = ed25519.Verify(pub, data, signature)
+	default:
+		return errors.New("unknown signature algorithm")
+	}
+
+	if !ok {
+		return ErrSignature
+	}
+	return nil
+}
+
+// SignBase64 Sign with a base64 signature
+func SignBase64(data, privateKey []byte, algorithm SignatureAlgorithm, t ...CertType) (string, error) {
+	sig, err := Sign(data, privateKey, algorithm, t...)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// VerifyBase64 Verify a base64 signature
+func VerifyBase64(data []byte, signature string, publicKey []byte, algorithm SignatureAlgorithm, t ...CertType) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrSignature
+	}
+	return Verify(data, sig, publicKey, algorithm, t...)
+}
+
+// SignHex Sign with a hex signature
+func SignHex(data, privateKey []byte, algorithm SignatureAlgorithm, t ...CertType) (string, error) {
+	sig, err := Sign(data, privateKey, algorithm, t...)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sig), nil
+}
+
+// VerifyHex Verify a hex signature
+func VerifyHex(data []byte, signature string, publicKey []byte, algorithm SignatureAlgorithm, t ...CertType) error {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrSignature
+	}
+	return Verify(data, sig, publicKey, algorithm, t...)
+}
+
+// parsePrivateKey parse a PEM private key of CertType, the form is detected if t is empty
+func parsePrivateKey(key []byte, t []CertType) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("key error")
+	}
+	var certType CertType
+	if len(t) > 0 {
+		certType = t[0]
+	} else {
+		var private bool
+		var err error
+		if certType, private, err = DetectCertType(key); err != nil {
+			return nil, err
+		}
+		if !private {
+			return nil, errors.New("not a private key")
+		}
+	}
+	switch certType {
+	case PKCS1:
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case SEC1:
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+}
+
+// parsePublicKey parse a PEM public key of CertType, the form is detected if t is empty
+func parsePublicKey(key []byte, t []CertType) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("key error")
+	}
+	var certType CertType
+	if len(t) > 0 {
+		certType = t[0]
+	} else {
+		var private bool
+		var err error
+		if certType, private, err = DetectCertType(key); err != nil {
+			return nil, err
+		}
+		if private {
+			return nil, errors.New("not a public key")
+		}
+	}
+	if certType == PKCS1 {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
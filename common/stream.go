@@ -0,0 +1,332 @@
+package common
+
+import (
+	"bufio"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Streams of EncryptWriter are a header followed by chunks sealed with a key derived from the
+// key or password and the random salt of the header. The nonce of each chunk is its counter
+// and a flag marking the last chunk, so reordered, removed or truncated chunks fail to open.
+//
+//	magic "GTSE" | version | algorithm | kdf | chunk size (4 bytes) | salt (16 bytes) | argon2 time, memory (4 bytes each), threads (1 byte)
+//	chunk 0 | chunk 1 | ... | last chunk, each at most chunk size + 16 bytes
+
+const (
+	streamMagic   = "GTSE"
+	streamVersion = 1
+	// StreamChunkSize plaintext bytes per chunk
+	StreamChunkSize = 64 << 10
+	streamSaltSize  = 16
+
+	kdfKey    = 0
+	kdfArgon2 = 1
+
+	// argon2id parameters of PasswordEncryptWriter
+	argon2Time    = 3
+	argon2Memory  = 64 << 10
+	argon2Threads = 4
+	// limits of argon2id parameters read from headers and hashes, memory in KiB
+	argon2MaxTime    = 16
+	argon2MaxMemory  = 256 << 10
+	argon2MaxThreads = 16
+)
+
+// ErrTruncated encrypted stream ends before its last chunk
+var ErrTruncated = errors.New("encrypted stream truncated")
+
+// streamHeader header of an encrypted stream
+type streamHeader struct {
+	algorithm AEADAlgorithm
+	kdf       byte
+	chunkSize uint32
+	salt      [streamSaltSize]byte
+	time      uint32
+	memory    uint32
+	threads   uint8
+}
+
+func (h *streamHeader) bytes() []byte {
+	b := make([]byte, 0, 40)
+	b = append(b, streamMagic...)
+	b = append(b, streamVersion, byte(h.algorithm), h.kdf)
+	b = binary.BigEndian.AppendUint32(b, h.chunkSize)
+	b = append(b, h.salt[:]...)
+	if h.kdf == kdfArgon2 {
+		b = binary.BigEndian.AppendUint32(b, h.time)
+		b = binary.BigEndian.AppendUint32(b, h.memory)
+		b = append(b, h.threads)
+	}
+	return b
+}
+
+func readStreamHeader(r io.Reader) (*streamHeader, []byte, error) {
+	b := make([]byte, 11+streamSaltSize, 11+streamSaltSize+9)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, nil, fmt.Errorf("encrypted stream header: %w", err)
+	}
+	if string(b[:4]) != streamMagic || b[4] != streamVersion {
+		return nil, nil, errors.New("not an encrypted stream or unknown version")
+	}
+	h := &streamHeader{algorithm: AEADAlgorithm(b[5]), kdf: b[6], chunkSize: binary.BigEndian.Uint32(b[7:11])}
+	copy(h.salt[:], b[11:])
+	if h.chunkSize == 0 || h.chunkSize > 16<<20 {
+		return nil, nil, errors.New("encrypted stream chunk size")
+	}
+
+	switch h.kdf {
+	case kdfKey:
+	case kdfArgon2:
+		params := make([]byte, 9)
+		if _, err := io.ReadFull(r, params); err != nil {
+			return nil, nil, fmt.Errorf("encrypted stream header: %w", err)
+		}
+		b = append(b, params...)
+		h.time, h.memory, h.threads = binary.BigEndian.Uint32(params), binary.BigEndian.Uint32(params[4:]), params[8]
+		if err := checkArgon2(h.time, h.memory, h.threads); err != nil {
+			return nil, nil, fmt.Errorf("encrypted stream: %w", err)
+		}
+	default:
+		return nil, nil, errors.New("encrypted stream key derivation")
+	}
+	return h, b, nil
+}
+
+// checkArgon2 refuse argon2id parameters of untrusted input taking too long or too much memory
+func checkArgon2(time, memory uint32, threads uint8) error {
+	if time == 0 || time > argon2MaxTime || memory > argon2MaxMemory || threads == 0 || threads > argon2MaxThreads {
+		return fmt.Errorf("argon2id parameters t=%d m=%d p=%d out of range", time, memory, threads)
+	}
+	return nil
+}
+
+// aead chunk cipher of the stream
+func (h *streamHeader) aead(key []byte, password string) (cipher.AEAD, error) {
+	fileKey := make([]byte, AEADKeySize)
+	switch h.kdf {
+	case kdfArgon2:
+		fileKey = argon2.IDKey([]byte(password), h.salt[:], h.time, h.memory, h.threads, AEADKeySize)
+	default:
+		if len(key) != AEADKeySize {
+			return nil, fmt.Errorf("stream key must be %d bytes", AEADKeySize)
+		}
+		if _, err := io.ReadFull(hkdf.New(sha256.New, key, h.salt[:], []byte("gotools stream")), fileKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return newCipherAEAD(h.algorithm, fileKey)
+}
+
+// streamNonce counter and last chunk flag
+func streamNonce(nonce []byte, counter uint64, last bool) {
+	for i := range nonce {
+		nonce[i] = 0
+	}
+	binary.BigEndian.PutUint64(nonce[len(nonce)-9:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+}
+
+// encryptWriter io.WriteCloser of EncryptWriter
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	sealed  []byte
+	nonce   []byte
+	counter uint64
+	closed  bool
+}
+
+// EncryptWriter encrypt everything written to the returned writer into w with a 32 bytes key,
+// Close must be called to write the last chunk and does not close w
+func EncryptWriter(w io.Writer, algorithm AEADAlgorithm, key []byte) (io.WriteCloser, error) {
+	h := &streamHeader{algorithm: algorithm, kdf: kdfKey, chunkSize: StreamChunkSize}
+	return newEncryptWriter(w, h, key, "")
+}
+
+// PasswordEncryptWriter EncryptWriter with a key derived from password by argon2id
+func PasswordEncryptWriter(w io.Writer, algorithm AEADAlgorithm, password string) (io.WriteCloser, error) {
+	if password == "" {
+		return nil, errors.New("password is empty")
+	}
+	h := &streamHeader{algorithm: algorithm, kdf: kdfArgon2, chunkSize: StreamChunkSize,
+		time: argon2Time, memory: argon2Memory, threads: argon2Threads}
+	return newEncryptWriter(w, h, nil, password)
+}
+
+func newEncryptWriter(w io.Writer, h *streamHeader, key []byte, password string) (*encryptWriter, error) {
+	if _, err := crand.Read(h.salt[:]); err != nil {
+		return nil, err
+	}
+	aead, err := h.aead(key, password)
+	if err != nil {
+		return nil, err
+	}
+	header := h.bytes()
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, h.chunkSize),
+		sealed: make([]byte, 0, int(h.chunkSize)+aead.Overhead()),
+		nonce:  make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed encrypt writer")
+	}
+	n := 0
+	for len(p) > 0 {
+		// a full chunk is sealed only when more data follows, so Close can mark the last one
+		if len(e.buf) == cap(e.buf) {
+			if err := e.seal(false); err != nil {
+				return n, err
+			}
+		}
+		m := copy(e.buf[len(e.buf):cap(e.buf)], p)
+		e.buf = e.buf[:len(e.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	streamNonce(e.nonce, e.counter, last)
+	e.sealed = e.aead.Seal(e.sealed[:0], e.nonce, e.buf, e.header)
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(e.sealed)
+	return err
+}
+
+// Close write the last chunk
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+// decryptReader io.Reader of DecryptReader
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	sealed  []byte
+	buf     []byte
+	plain   []byte
+	nonce   []byte
+	counter uint64
+	done    bool
+	err     error
+}
+
+// DecryptReader decrypt the stream of EncryptWriter read from r with its key, reads fail with
+// ErrTruncated on streams cut between chunks and ErrDecrypt on modified or otherwise cut streams
+func DecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	return newDecryptReader(r, key, "", false)
+}
+
+// PasswordDecryptReader decrypt the stream of PasswordEncryptWriter read from r with password
+func PasswordDecryptReader(r io.Reader, password string) (io.Reader, error) {
+	return newDecryptReader(r, nil, password, true)
+}
+
+func newDecryptReader(r io.Reader, key []byte, password string, usePassword bool) (*decryptReader, error) {
+	h, header, err := readStreamHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if usePassword != (h.kdf == kdfArgon2) {
+		if usePassword {
+			return nil, errors.New("stream is encrypted with a key, not a password")
+		}
+		return nil, errors.New("stream is encrypted with a password")
+	}
+	aead, err := h.aead(key, password)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:      bufio.NewReaderSize(r, int(h.chunkSize)+aead.Overhead()),
+		aead:   aead,
+		header: header,
+		sealed: make([]byte, int(h.chunkSize)+aead.Overhead()),
+		buf:    make([]byte, 0, h.chunkSize),
+		nonce:  make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// next open the next chunk, a chunk shorter than the chunk size or followed by the end is the last one
+func (d *decryptReader) next() error {
+	n, err := io.ReadFull(d.r, d.sealed)
+	last := false
+	switch err {
+	case nil:
+		if _, e := d.r.Peek(1); e == io.EOF {
+			last = true
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+	if n == 0 {
+		return ErrTruncated
+	}
+
+	plain, err := d.open(n, last)
+	if err != nil {
+		// a stream cut after a chunk ends with a chunk that is not the last one
+		if last {
+			if _, e := d.open(n, false); e == nil {
+				return ErrTruncated
+			}
+		}
+		return ErrDecrypt
+	}
+	d.counter++
+	d.plain = plain
+	d.done = last
+	return nil
+}
+
+func (d *decryptReader) open(n int, last bool) ([]byte, error) {
+	streamNonce(d.nonce, d.counter, last)
+	return d.aead.Open(d.buf[:0], d.nonce, d.sealed[:n], d.header)
+}
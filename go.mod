@@ -1,5 +1,10 @@
 module github.com/kbrownehs18/gotools
 
-go 1.12
+go 1.20
 
 require github.com/google/uuid v1.2.0
+
+require (
+	golang.org/x/crypto v0.17.0
+	golang.org/x/sys v0.15.0 // indirect
+)
@@ -0,0 +1,401 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+// AuditLogger tamper evident log, each entry carries the HMAC of the previous entry
+// and its own HMAC, so modified or deleted entries break the chain
+//
+//	{"seq":2,"time":"...","msg":"login","user":"bob","prev":"<mac of seq 1>","mac":"<mac of this entry>"}
+type AuditLogger struct {
+	fileHandler *FileHandler
+	key         string
+	lock        sync.Mutex
+	seq         uint64
+	prev        string
+}
+
+const (
+	// auditMacSuffix entries end with ,"mac":"<hex>"}
+	auditMacSuffix = `,"mac":"`
+	// auditCheckpointSuffix the retention checkpoint of audit.log is audit.log.checkpoint
+	auditCheckpointSuffix = ".checkpoint"
+)
+
+// NewAuditLogger new audit logger writing to fileHandler, the chain continues
+// from the last entry of the log file or its newest backup.
+// Before rotation removes a backup, its last entry is recorded in the retention checkpoint
+// so VerifyAudit knows where the remaining chain starts.
+func NewAuditLogger(fileHandler *FileHandler, key string) (*AuditLogger, error) {
+	if key == "" {
+		return nil, errors.New("audit key is empty")
+	}
+	a := &AuditLogger{fileHandler: fileHandler, key: key}
+	fs := fileHandler.fs
+
+	files, err := backupFiles(fs, fileHandler.fileName)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		e, err := lastAuditEntry(fs, files[i], key)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			a.seq, a.prev = e.Seq, e.mac
+			break
+		}
+	}
+	if a.seq == 0 {
+		// every entry was removed by the rotation
+		cp, err := readAuditCheckpoint(fs, fileHandler.fileName, key)
+		if err != nil {
+			return nil, err
+		}
+		if cp != nil {
+			a.seq, a.prev = cp.Seq, cp.Last
+		}
+	}
+
+	fileHandler.lock.Lock()
+	fileHandler.onRemove = a.checkpoint
+	fileHandler.lock.Unlock()
+	return a, nil
+}
+
+// checkpoint record the last entry of the backup name before the rotation removes it
+func (a *AuditLogger) checkpoint(name string) error {
+	fs := a.fileHandler.fs
+	e, err := lastAuditEntry(fs, name, a.key)
+	if err != nil || e == nil {
+		return err
+	}
+	// daily retention may remove a backup newer than the checkpoint of an older one
+	cp, err := readAuditCheckpoint(fs, a.fileHandler.fileName, a.key)
+	if err != nil {
+		return err
+	}
+	if cp != nil && cp.Seq >= e.Seq {
+		return nil
+	}
+
+	body := fmt.Sprintf(`{"checkpoint":%d,"last":"%s"}`, e.Seq, e.mac)
+	line := body[:len(body)-1] + auditMacSuffix + common.HMacSHA256(body, a.key) + "\"}\n"
+
+	// replace the checkpoint at once, a crash leaves the old one
+	fileName := a.fileHandler.fileName + auditCheckpointSuffix
+	fd, err := fs.OpenFile(fileName+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err = fd.Write([]byte(line)); err == nil {
+		err = fd.Sync()
+	}
+	if e := fd.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return fs.Rename(fileName+".tmp", fileName)
+}
+
+// Log write an audit entry
+func (a *AuditLogger) Log(message string, fields ...Field) error {
+	enc := getEncoder()
+	defer putEncoder(enc)
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	enc.buf = append(enc.buf, `{"seq":`...)
+	enc.buf = strconv.AppendUint(enc.buf, a.seq+1, 10)
+	enc.buf = append(enc.buf, `,"time":"`...)
+	enc.buf = time.Now().AppendFormat(enc.buf, "2006-01-02T15:04:05.000Z07:00")
+	enc.buf = append(enc.buf, `","msg":`...)
+	enc.appendJSONString(message)
+	for i := range fields {
+		switch fields[i].Key {
+		case "seq", "time", "msg", "prev", "mac":
+			return fmt.Errorf("audit field %s is reserved", fields[i].Key)
+		}
+		enc.buf = append(enc.buf, ',')
+		enc.appendJSONString(fields[i].Key)
+		enc.buf = append(enc.buf, ':')
+		if err := enc.appendJSONValue(&fields[i]); err != nil {
+			return err
+		}
+	}
+	enc.buf = append(enc.buf, `,"prev":"`...)
+	enc.buf = append(enc.buf, a.prev...)
+	enc.buf = append(enc.buf, `"}`...)
+
+	mac := common.HMacSHA256(string(enc.buf), a.key)
+	enc.buf = append(enc.buf[:len(enc.buf)-1], auditMacSuffix...)
+	enc.buf = append(enc.buf, mac...)
+	enc.buf = append(enc.buf, "\"}\n"...)
+
+	if _, err := a.fileHandler.Write(enc.buf); err != nil {
+		return err
+	}
+	a.seq++
+	a.prev = mac
+	return nil
+}
+
+// auditEntry fields of an entry checked by the chain
+type auditEntry struct {
+	Seq  uint64 `json:"seq"`
+	Prev string `json:"prev"`
+	mac  string
+}
+
+// auditCheckpoint last entry of the removed backups
+type auditCheckpoint struct {
+	Seq  uint64 `json:"checkpoint"`
+	Last string `json:"last"`
+}
+
+// checkAuditMac body of a line without its mac and the mac, if the HMAC matches
+func checkAuditMac(line, key string) (body, mac string, err error) {
+	i := strings.LastIndex(line, auditMacSuffix)
+	if i < 0 || !strings.HasSuffix(line, `"}`) {
+		return "", "", errors.New("no mac")
+	}
+	body = line[:i] + "}"
+	mac = line[i+len(auditMacSuffix) : len(line)-2]
+	if !hmac.Equal([]byte(mac), []byte(common.HMacSHA256(body, key))) {
+		return "", "", errors.New("mac mismatch, entry modified or wrong key")
+	}
+	return body, mac, nil
+}
+
+// parseAuditEntry check the HMAC of an entry line
+func parseAuditEntry(line, key string) (*auditEntry, error) {
+	body, mac, err := checkAuditMac(line, key)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(body, `{"seq":`) {
+		return nil, errors.New("not an entry")
+	}
+
+	e := &auditEntry{mac: mac}
+	if err := json.Unmarshal([]byte(body), e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// lastAuditEntry last entry of a log file, nil if it is empty
+func lastAuditEntry(fs FileSystem, fileName, key string) (*auditEntry, error) {
+	var last string
+	err := readAuditLines(fs, fileName, func(line string) error {
+		last = line
+		return nil
+	})
+	if err != nil || last == "" {
+		return nil, err
+	}
+	e, err := parseAuditEntry(last, key)
+	if err != nil {
+		return nil, fmt.Errorf("audit log %s last entry: %s", fileName, err.Error())
+	}
+	return e, nil
+}
+
+// readAuditCheckpoint retention checkpoint of the audit log fileName, nil if no backup was removed yet,
+// an invalid checkpoint is an *AuditBreak
+func readAuditCheckpoint(fs FileSystem, fileName, key string) (*auditCheckpoint, error) {
+	fileName += auditCheckpointSuffix
+	var line string
+	err := readAuditLines(fs, fileName, func(l string) error {
+		line = l
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body, _, err := checkAuditMac(line, key)
+	cp := &auditCheckpoint{}
+	if err == nil && !strings.HasPrefix(body, `{"checkpoint":`) {
+		err = errors.New("not a checkpoint")
+	}
+	if err == nil {
+		err = json.Unmarshal([]byte(body), cp)
+	}
+	if err != nil {
+		return nil, &AuditBreak{File: fileName, Line: 1, Reason: "checkpoint: " + err.Error()}
+	}
+	return cp, nil
+}
+
+// readAuditLines call fn with each line of a log file, gzipped backups are decompressed
+func readAuditLines(fs FileSystem, fileName string, fn func(line string) error) error {
+	fd, err := fs.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	var r io.Reader = fd
+	if strings.HasSuffix(fileName, ".gz") {
+		gz, err := gzip.NewReader(fd)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			if e := fn(line); e != nil {
+				return e
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// AuditBreak first broken link of an audit chain
+type AuditBreak struct {
+	File string
+	// Line line number in File, from 1
+	Line   int
+	Seq    uint64
+	Reason string
+}
+
+func (b *AuditBreak) Error() string {
+	return fmt.Sprintf("%s:%d: seq %d: %s", b.File, b.Line, b.Seq, b.Reason)
+}
+
+// AuditResult result of VerifyAudit
+type AuditResult struct {
+	// Files verified files, oldest first
+	Files []string
+	// Stale backups entirely before the checkpoint, left by the daily retention, not verified
+	Stale   []string
+	Entries int
+	// FirstSeq, LastSeq seq of the first and the last verified entries, 0 if there are none
+	FirstSeq uint64
+	LastSeq  uint64
+	// Checkpoint seq of the last entry removed by the rotation, 0 if the chain starts at seq 1
+	Checkpoint uint64
+	// Broken nil if the chain is intact
+	Broken *AuditBreak
+}
+
+// VerifyAudit walk the audit log fileName and its backups oldest first, report the first broken link.
+// The chain starts at seq 1, or after the retention checkpoint once rotation removed old backups.
+func VerifyAudit(fileName, key string) (*AuditResult, error) {
+	return VerifyAuditFS(osFileSystem{}, fileName, key)
+}
+
+// VerifyAuditFS VerifyAudit of an audit log on fs
+func VerifyAuditFS(fs FileSystem, fileName, key string) (*AuditResult, error) {
+	files, err := backupFiles(fs, fileName)
+	if err != nil {
+		return nil, err
+	}
+	result := &AuditResult{}
+
+	cp, err := readAuditCheckpoint(fs, fileName, key)
+	if b, ok := err.(*AuditBreak); ok {
+		result.Files = files
+		result.Broken = b
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	start, startPrev := uint64(1), ""
+	if cp != nil {
+		result.Checkpoint = cp.Seq
+		start, startPrev = cp.Seq+1, cp.Last
+	}
+
+	var last *auditEntry
+	for _, file := range files {
+		if last == nil && cp != nil {
+			// a broken entry is reported by the walk below
+			if e, err := lastAuditEntry(fs, file, key); err == nil && e != nil && e.Seq <= cp.Seq {
+				result.Stale = append(result.Stale, file)
+				continue
+			}
+		}
+		result.Files = append(result.Files, file)
+		n := 0
+		err := readAuditLines(fs, file, func(line string) error {
+			n++
+			e, err := parseAuditEntry(line, key)
+			if err != nil {
+				seq := start
+				if last != nil {
+					seq = last.Seq + 1
+				}
+				return &AuditBreak{File: file, Line: n, Seq: seq, Reason: err.Error()}
+			}
+			if last == nil {
+				if e.Seq != start {
+					return &AuditBreak{File: file, Line: n, Seq: e.Seq,
+						Reason: fmt.Sprintf("chain starts at seq %d instead of %d, entries removed", e.Seq, start)}
+				}
+				if e.Prev != startPrev {
+					return &AuditBreak{File: file, Line: n, Seq: e.Seq,
+						Reason: "prev mac does not match the start of the chain, entries removed or replaced"}
+				}
+				result.FirstSeq = e.Seq
+			} else {
+				if e.Prev != last.mac {
+					return &AuditBreak{File: file, Line: n, Seq: e.Seq,
+						Reason: fmt.Sprintf("prev mac does not match entry %d, entries removed or reordered", last.Seq)}
+				}
+				if e.Seq != last.Seq+1 {
+					return &AuditBreak{File: file, Line: n, Seq: e.Seq,
+						Reason: fmt.Sprintf("seq follows %d", last.Seq)}
+				}
+			}
+			last = e
+			result.LastSeq = e.Seq
+			result.Entries++
+			return nil
+		})
+		if b, ok := err.(*AuditBreak); ok {
+			result.Broken = b
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
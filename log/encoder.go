@@ -0,0 +1,272 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxPooledBuffer encoders with larger buffers are not put back to the pool
+const maxPooledBuffer = 64 << 10
+
+// encoder builds one entry in its buffer, encoders are pooled
+type encoder struct {
+	buf []byte
+}
+
+var encoderPool = sync.Pool{New: func() interface{} { return &encoder{buf: make([]byte, 0, 1024)} }}
+
+func getEncoder() *encoder {
+	return encoderPool.Get().(*encoder)
+}
+
+func putEncoder(enc *encoder) {
+	if cap(enc.buf) > maxPooledBuffer {
+		return
+	}
+	enc.buf = enc.buf[:0]
+	encoderPool.Put(enc)
+}
+
+// entry a log entry, formatted by one call, fields are passed to encoders
+// separately so they do not escape with the entry
+type entry struct {
+	time    time.Time
+	level   Level
+	logger  string
+	message string
+	// callerFormat 0 if no caller
+	callerFormat CallerFormat
+	pc           uintptr
+	file         string
+	line         int
+	err          *errorInfo
+	// errField index of the field rendered as err, -1 if none
+	errField int
+}
+
+// encodeText [name][LEVEL]2006/01/02 15:04:05 file.go:12: message key=value
+func (enc *encoder) encodeText(e *entry, fields []Field) {
+	enc.buf = append(enc.buf, '[')
+	enc.buf = append(enc.buf, e.logger...)
+	enc.buf = append(enc.buf, "]["...)
+	enc.buf = append(enc.buf, e.level.String()...)
+	enc.buf = append(enc.buf, ']')
+	enc.buf = e.time.AppendFormat(enc.buf, "2006/01/02 15:04:05")
+	enc.buf = append(enc.buf, ' ')
+	if e.callerFormat != 0 {
+		enc.appendCaller(e)
+		enc.buf = append(enc.buf, ": "...)
+	}
+	enc.buf = append(enc.buf, e.message...)
+	for i := range fields {
+		if i == e.errField {
+			continue
+		}
+		f := &fields[i]
+		enc.buf = append(enc.buf, ' ')
+		enc.buf = append(enc.buf, f.Key...)
+		enc.buf = append(enc.buf, '=')
+		enc.appendTextValue(f)
+	}
+	if e.err != nil {
+		enc.buf = append(enc.buf, e.err.text()...)
+	}
+	enc.buf = append(enc.buf, '\n')
+}
+
+// appendCaller log.go:12, /src/app/log.go:12 or app.(*Server).Start:12
+func (enc *encoder) appendCaller(e *entry) {
+	switch e.callerFormat {
+	case LONGFILE:
+		enc.buf = append(enc.buf, e.file...)
+	case FUNCNAME:
+		name := "???"
+		if f := runtime.FuncForPC(e.pc); f != nil {
+			name = f.Name()
+			if i := strings.LastIndex(name, "/"); i >= 0 {
+				name = name[i+1:]
+			}
+		}
+		enc.buf = append(enc.buf, name...)
+	default:
+		enc.buf = append(enc.buf, filepath.Base(e.file)...)
+	}
+	enc.buf = append(enc.buf, ':')
+	enc.buf = strconv.AppendInt(enc.buf, int64(e.line), 10)
+}
+
+func (enc *encoder) appendTextValue(f *Field) {
+	switch f.typ {
+	case stringField:
+		enc.appendTextString(f.str)
+	case intField:
+		enc.buf = strconv.AppendInt(enc.buf, f.integer, 10)
+	case uintField:
+		enc.buf = strconv.AppendUint(enc.buf, uint64(f.integer), 10)
+	case floatField:
+		enc.buf = strconv.AppendFloat(enc.buf, math.Float64frombits(uint64(f.integer)), 'g', -1, 64)
+	case boolField:
+		enc.buf = strconv.AppendBool(enc.buf, f.integer == 1)
+	case durationField:
+		enc.buf = append(enc.buf, time.Duration(f.integer).String()...)
+	case timeField:
+		enc.buf = fieldTime(f).AppendFormat(enc.buf, "2006-01-02T15:04:05.000Z07:00")
+	case errorField:
+		if err, ok := f.value.(error); ok && err != nil {
+			enc.appendTextString(err.Error())
+		} else {
+			enc.buf = append(enc.buf, "<nil>"...)
+		}
+	default:
+		enc.appendTextString(fmt.Sprint(f.value))
+	}
+}
+
+// appendTextString quote values with spaces, quotes or equal signs so they parse back
+func (enc *encoder) appendTextString(s string) {
+	quote := s == ""
+	for i := 0; i < len(s) && !quote; i++ {
+		c := s[i]
+		quote = c <= ' ' || c == '"' || c == '=' || c >= utf8.RuneSelf
+	}
+	if quote {
+		enc.buf = strconv.AppendQuote(enc.buf, s)
+	} else {
+		enc.buf = append(enc.buf, s...)
+	}
+}
+
+// encodeJSON one object per line
+func (enc *encoder) encodeJSON(e *entry, fields []Field) error {
+	enc.buf = append(enc.buf, `{"time":"`...)
+	enc.buf = e.time.AppendFormat(enc.buf, "2006-01-02T15:04:05.000Z07:00")
+	enc.buf = append(enc.buf, `","level":"`...)
+	enc.buf = append(enc.buf, e.level.String()...)
+	enc.buf = append(enc.buf, `","logger":`...)
+	enc.appendJSONString(e.logger)
+	if e.callerFormat != 0 {
+		enc.buf = append(enc.buf, `,"caller":`...)
+		start := len(enc.buf)
+		enc.appendCaller(e)
+		caller := string(enc.buf[start:])
+		enc.buf = enc.buf[:start]
+		enc.appendJSONString(caller)
+	}
+	enc.buf = append(enc.buf, `,"msg":`...)
+	enc.appendJSONString(e.message)
+	for i := range fields {
+		if i == e.errField {
+			continue
+		}
+		f := &fields[i]
+		enc.buf = append(enc.buf, ',')
+		enc.appendJSONString(f.Key)
+		enc.buf = append(enc.buf, ':')
+		if err := enc.appendJSONValue(f); err != nil {
+			return err
+		}
+	}
+	if e.err != nil {
+		b, err := json.Marshal(e.err)
+		if err != nil {
+			return err
+		}
+		enc.buf = append(enc.buf, `,"error":`...)
+		enc.buf = append(enc.buf, b...)
+	}
+	enc.buf = append(enc.buf, "}\n"...)
+	return nil
+}
+
+func (enc *encoder) appendJSONValue(f *Field) error {
+	switch f.typ {
+	case stringField:
+		enc.appendJSONString(f.str)
+	case intField:
+		enc.buf = strconv.AppendInt(enc.buf, f.integer, 10)
+	case uintField:
+		enc.buf = strconv.AppendUint(enc.buf, uint64(f.integer), 10)
+	case floatField:
+		v := math.Float64frombits(uint64(f.integer))
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			// not valid json numbers
+			enc.appendJSONString(strconv.FormatFloat(v, 'g', -1, 64))
+		} else {
+			enc.buf = strconv.AppendFloat(enc.buf, v, 'g', -1, 64)
+		}
+	case boolField:
+		enc.buf = strconv.AppendBool(enc.buf, f.integer == 1)
+	case durationField:
+		enc.appendJSONString(time.Duration(f.integer).String())
+	case timeField:
+		enc.buf = append(enc.buf, '"')
+		enc.buf = fieldTime(f).AppendFormat(enc.buf, "2006-01-02T15:04:05.000Z07:00")
+		enc.buf = append(enc.buf, '"')
+	case errorField:
+		if err, ok := f.value.(error); ok && err != nil {
+			enc.appendJSONString(err.Error())
+		} else {
+			enc.buf = append(enc.buf, "null"...)
+		}
+	default:
+		b, err := json.Marshal(f.value)
+		if err != nil {
+			return err
+		}
+		enc.buf = append(enc.buf, b...)
+	}
+	return nil
+}
+
+const hex = "0123456789abcdef"
+
+// appendJSONString quoted json string, invalid utf-8 is replaced by U+FFFD
+func (enc *encoder) appendJSONString(s string) {
+	enc.buf = append(enc.buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				enc.buf = append(enc.buf, '\\', c)
+			case c == '\n':
+				enc.buf = append(enc.buf, '\\', 'n')
+			case c == '\r':
+				enc.buf = append(enc.buf, '\\', 'r')
+			case c == '\t':
+				enc.buf = append(enc.buf, '\\', 't')
+			case c < ' ':
+				enc.buf = append(enc.buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			default:
+				enc.buf = append(enc.buf, c)
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			enc.buf = append(enc.buf, "\ufffd"...)
+		} else {
+			enc.buf = append(enc.buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	enc.buf = append(enc.buf, '"')
+}
+
+func fieldTime(f *Field) time.Time {
+	t := time.Unix(0, f.integer)
+	if loc, ok := f.value.(*time.Location); ok && loc != nil {
+		t = t.In(loc)
+	}
+	return t
+}
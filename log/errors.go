@@ -0,0 +1,155 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// maxCauses limit of causes recorded for one error
+const maxCauses = 32
+
+// maxStackDepth limit of frames captured for one error
+const maxStackDepth = 64
+
+// errorCause an error in the chain of the logged error
+type errorCause struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	// Depth 1 for direct causes, errors.Join members share a depth
+	Depth int `json:"depth"`
+}
+
+// stackFrame a function call in a stack trace
+type stackFrame struct {
+	Function string `json:"func"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// errorInfo logged error with its causes and stack trace
+type errorInfo struct {
+	Message string       `json:"message"`
+	Type    string       `json:"type"`
+	Causes  []errorCause `json:"causes,omitempty"`
+	Stack   []stackFrame `json:"stack,omitempty"`
+}
+
+// findError return the first error of log arguments
+func findError(v []interface{}) error {
+	for _, arg := range v {
+		if err, ok := arg.(error); ok && err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newErrorInfo walk the Unwrap chain of err, use the stack carried by the deepest error,
+// or capture the stack of the log call if capture, skipping skip frames from the caller of newErrorInfo
+func newErrorInfo(err error, capture bool, skip int) *errorInfo {
+	info := &errorInfo{Message: err.Error(), Type: fmt.Sprintf("%T", err)}
+
+	pcs := errorStack(err)
+
+	type node struct {
+		err   error
+		depth int
+	}
+	pending := []node{{err, 0}}
+	for len(pending) > 0 && len(info.Causes) < maxCauses {
+		n := pending[0]
+		pending = pending[1:]
+		if n.depth > 0 {
+			info.Causes = append(info.Causes, errorCause{Message: n.err.Error(),
+				Type: fmt.Sprintf("%T", n.err), Depth: n.depth})
+			if pc := errorStack(n.err); pc != nil {
+				pcs = pc
+			}
+		}
+
+		// depth first, keep causes in reading order
+		var children []node
+		switch e := n.err.(type) {
+		case interface{ Unwrap() error }:
+			if c := e.Unwrap(); c != nil {
+				children = append(children, node{c, n.depth + 1})
+			}
+		case interface{ Unwrap() []error }:
+			for _, c := range e.Unwrap() {
+				if c != nil {
+					children = append(children, node{c, n.depth + 1})
+				}
+			}
+		}
+		pending = append(children, pending...)
+	}
+
+	if pcs == nil && capture {
+		pcs = make([]uintptr, maxStackDepth)
+		pcs = pcs[:runtime.Callers(skip+2, pcs)]
+	}
+	info.Stack = stackFrames(pcs)
+
+	return info
+}
+
+// errorStack program counters of errors carrying a stack trace, like
+// StackTrace() []uintptr, or github.com/pkg/errors StackTrace() errors.StackTrace
+func errorStack(err error) []uintptr {
+	if e, ok := err.(interface{ StackTrace() []uintptr }); ok {
+		return e.StackTrace()
+	}
+
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil
+	}
+	out := m.Type().Out(0)
+	if out.Kind() != reflect.Slice || out.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+
+	st := m.Call(nil)[0]
+	pcs := make([]uintptr, st.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(st.Index(i).Uint())
+	}
+	return pcs
+}
+
+func stackFrames(pcs []uintptr) []stackFrame {
+	if len(pcs) == 0 {
+		return nil
+	}
+
+	frames := runtime.CallersFrames(pcs)
+	stack := make([]stackFrame, 0, len(pcs))
+	for {
+		f, more := frames.Next()
+		if f.Function != "" && !strings.HasPrefix(f.Function, "runtime.") {
+			stack = append(stack, stackFrame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// text indented block appended to text log message
+func (e *errorInfo) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n    error: %s (%s)", e.Message, e.Type)
+	for _, c := range e.Causes {
+		fmt.Fprintf(&b, "\n    %scause: %s (%s)", strings.Repeat("  ", c.Depth), c.Message, c.Type)
+	}
+	if len(e.Stack) > 0 {
+		b.WriteString("\n    stack:")
+		for _, f := range e.Stack {
+			fmt.Fprintf(&b, "\n      %s\n        %s:%d", f.Function, f.File, f.Line)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"math"
+	"time"
+)
+
+// fieldType type of the Field value
+type fieldType uint8
+
+const (
+	stringField fieldType = iota
+	intField
+	uintField
+	floatField
+	boolField
+	durationField
+	timeField
+	errorField
+	anyField
+)
+
+// Field typed key value pair of a log entry, build it with String, Int etc.
+// so disabled log calls do not allocate
+type Field struct {
+	Key     string
+	typ     fieldType
+	integer int64
+	str     string
+	value   interface{}
+}
+
+// String string field
+func String(key, value string) Field {
+	return Field{Key: key, typ: stringField, str: value}
+}
+
+// Int int field
+func Int(key string, value int) Field {
+	return Field{Key: key, typ: intField, integer: int64(value)}
+}
+
+// Int64 int64 field
+func Int64(key string, value int64) Field {
+	return Field{Key: key, typ: intField, integer: value}
+}
+
+// Uint64 uint64 field
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, typ: uintField, integer: int64(value)}
+}
+
+// Float64 float64 field
+func Float64(key string, value float64) Field {
+	return Field{Key: key, typ: floatField, integer: int64(math.Float64bits(value))}
+}
+
+// Bool bool field
+func Bool(key string, value bool) Field {
+	var i int64
+	if value {
+		i = 1
+	}
+	return Field{Key: key, typ: boolField, integer: i}
+}
+
+// Duration time.Duration field, written as 1.5s
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, typ: durationField, integer: int64(value)}
+}
+
+// Time time.Time field, written in RFC3339 with milliseconds
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, typ: timeField, integer: value.UnixNano(), value: value.Location()}
+}
+
+// Err error field with key "error", logged with its causes and stack like error arguments of Error
+func Err(err error) Field {
+	return Field{Key: "error", typ: errorField, value: err}
+}
+
+// Any field of any value, written by fmt in text and encoding/json in JSON entries
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, typ: anyField, value: value}
+}
+
+// fieldsError index of the first error of fields, -1 if none
+func fieldsError(fields []Field) (int, error) {
+	for i := range fields {
+		if fields[i].typ == errorField {
+			if err, ok := fields[i].value.(error); ok && err != nil {
+				return i, err
+			}
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,37 @@
+package log
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+// HTTPLogging common.Middleware logging each outbound request with its method, url, status and duration,
+// at INFO, WARNING for 5xx responses and ERROR for failed requests. The query string is not logged.
+//
+//	client := common.NewClient(log.HTTPLogging(logger))
+func HTTPLogging(logger *Logger) common.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return common.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next.RoundTrip(req)
+			duration := time.Since(start)
+
+			url := *req.URL
+			url.RawQuery, url.Fragment = "", ""
+			switch {
+			case err != nil:
+				logger.Errorw("http request failed", String("method", req.Method), String("url", url.Redacted()),
+					Duration("duration", duration), Err(err))
+			case res.StatusCode >= 500:
+				logger.Warningw("http request", String("method", req.Method), String("url", url.Redacted()),
+					Int("status", res.StatusCode), Duration("duration", duration))
+			default:
+				logger.Infow("http request", String("method", req.Method), String("url", url.Redacted()),
+					Int("status", res.StatusCode), Duration("duration", duration))
+			}
+			return res, err
+		})
+	}
+}
@@ -3,10 +3,11 @@ package log
 import (
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kbrownehs18/gotools/common"
@@ -112,13 +113,69 @@ func NewAppender(name string) Appender {
 	return CONSOLE
 }
 
+// Format log entry format
+type Format int
+
+const (
+	// TEXT format
+	TEXT Format = 1 << iota
+	// JSON format, one object per line
+	JSON
+)
+
+// NewFormat new log format
+func NewFormat(name string) Format {
+	if strings.ToUpper(name) == "JSON" {
+		return JSON
+	}
+
+	return TEXT
+}
+
+// CallerFormat how the caller of a log call is written
+type CallerFormat int
+
+const (
+	// SHORTFILE file name and line, log.go:12
+	SHORTFILE CallerFormat = 1 << iota
+	// LONGFILE full file path and line, /src/app/log.go:12
+	LONGFILE
+	// FUNCNAME package qualified function name and line, app.(*Server).Start:12
+	FUNCNAME
+	// NOCALLER no caller, skip the runtime lookup
+	NOCALLER
+)
+
+// NewCallerFormat new caller format
+func NewCallerFormat(name string) CallerFormat {
+	name = strings.ToUpper(name)
+	switch name {
+	case "LONGFILE":
+		return LONGFILE
+	case "FUNCNAME":
+		return FUNCNAME
+	case "NOCALLER":
+		return NOCALLER
+	}
+
+	return SHORTFILE
+}
+
+// callerDepth frames from Logger.write to the caller of Error etc.
+const callerDepth = 3
+
 // Logger log struct
 type Logger struct {
-	appender    Appender
-	level       Level
-	logger      *log.Logger
-	fileHandler *FileHandler
-	name        string
+	appender     Appender
+	level        Level
+	format       Format
+	callerFormat CallerFormat
+	callerSkip   int
+	output       io.Writer
+	lock         *sync.Mutex
+	fileHandler  *FileHandler
+	name         string
+	entries      *levelCounters
 }
 
 // Level return log level
@@ -128,102 +185,197 @@ func (l *Logger) Level() Level {
 
 // FileHandler log file
 type FileHandler struct {
-	fd          *os.File
+	fd          File
+	fs          FileSystem
+	clock       Clock
 	fileName    string
 	rotate      Rotate
 	maxBytes    int
 	backupCount int
 	lock        *sync.Mutex
+	stats       *handlerCounters
+	// onRemove called with a file about to be removed or overwritten by the rotation,
+	// the file is kept if it fails
+	onRemove func(name string) error
 }
 
 func (fh *FileHandler) Write(b []byte) (n int, err error) {
-	fh.rollover()
-	return fh.fd.Write(b)
+	fh.lock.Lock()
+	defer fh.lock.Unlock()
+
+	if err = fh.rollover(); err != nil {
+		fh.stats.rotated(err)
+	}
+	if fh.fd == nil {
+		err = fmt.Errorf("log file %s is not opened: %v", fh.fileName, err)
+		fh.stats.written(0, err)
+		return 0, err
+	}
+
+	n, err = fh.fd.Write(b)
+	fh.stats.written(n, err)
+	return n, err
 }
 
-// Close file log handler close
+// Close file log handler close, its counters are no longer exported
 func (fh *FileHandler) Close() error {
+	fh.lock.Lock()
+	defer fh.lock.Unlock()
+
+	metrics.unregister(fh.stats)
 	if fh.fd != nil {
 		return fh.fd.Close()
 	}
 	return nil
 }
 
-func (fh *FileHandler) rollover() {
-	if fh.rotate == NONE {
-		return
+// Metrics return counters and health of the handler
+func (fh *FileHandler) Metrics() HandlerMetrics {
+	return fh.stats.snapshot()
+}
+
+func (fh *FileHandler) open() (err error) {
+	fh.fd, err = fh.fs.OpenFile(fh.fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		fh.fd = nil
 	}
+	return err
+}
 
-	fh.lock.Lock()
-	defer fh.lock.Unlock()
+func (fh *FileHandler) exists(name string) bool {
+	_, err := fh.fs.Stat(name)
+	return err == nil
+}
+
+// dropping call onRemove if name exists, fh.lock must be held
+func (fh *FileHandler) dropping(name string) error {
+	if fh.onRemove == nil || !fh.exists(name) {
+		return nil
+	}
+	return fh.onRemove(name)
+}
+
+func (fh *FileHandler) rename(oldPath, newPath string) error {
+	if err := fh.dropping(newPath); err != nil {
+		return err
+	}
+	return fh.fs.Rename(oldPath, newPath)
+}
+
+func (fh *FileHandler) remove(name string) error {
+	if err := fh.dropping(name); err != nil {
+		return err
+	}
+	return fh.fs.Remove(name)
+}
+
+// rollover rotate log file if needed, fh.lock must be held
+func (fh *FileHandler) rollover() error {
+	if fh.fd == nil {
+		// last rotation failed to reopen
+		return fh.open()
+	}
+	if fh.rotate == NONE {
+		return nil
+	}
 
 	f, err := fh.fd.Stat()
 	if err != nil {
-		return
+		return err
 	}
 
 	if fh.rotate == SIZE {
 		// rotating log by file size
 		if fh.maxBytes <= 0 {
 			// unlimited
-			return
+			return nil
 		} else if f.Size() < int64(fh.maxBytes) {
 			// no reach max limit
-			return
+			return nil
 		}
 	} else if fh.rotate == DAILY {
-		if common.TimeFormat(f.ModTime(), 1) == common.Now(1) {
+		if common.TimeFormat(f.ModTime(), 1) == common.TimeFormat(fh.clock.Now(), 1) {
 			// date is same
-			return
+			return nil
 		}
 	}
 
 	fh.fd.Close()
+	fh.fd = nil
 	if fh.backupCount > 0 {
 		if fh.rotate == SIZE {
 			for i := fh.backupCount - 1; i > 0; i-- {
 				sfn := fmt.Sprintf("%s.%d", fh.fileName, i)
 				dfn := fmt.Sprintf("%s.%d", fh.fileName, i+1)
-				if common.Exists(sfn) {
-					os.Rename(sfn, dfn)
+				if fh.exists(sfn) {
+					if err = fh.rename(sfn, dfn); err != nil {
+						break
+					}
 				}
 			}
 
-			dfn := fmt.Sprintf("%s.1", fh.fileName)
-			os.Rename(fh.fileName, dfn)
+			if err == nil {
+				dfn := fmt.Sprintf("%s.1", fh.fileName)
+				err = fh.rename(fh.fileName, dfn)
+			}
 		} else if fh.rotate == DAILY {
 			// remove
-			os.Remove(fmt.Sprintf("%s.%s", fh.fileName,
+			err = fh.remove(fmt.Sprintf("%s.%s", fh.fileName,
 				common.TimeFormat(f.ModTime().Add(0-time.Duration(fh.backupCount-1)*time.Hour*24), 1)))
-			os.Rename(fh.fileName, fmt.Sprintf("%s.%s", fh.fileName,
-				common.TimeFormat(time.Now().Add(0-time.Duration(1)*time.Hour*24), 1)))
+			if os.IsNotExist(err) {
+				err = nil
+			}
+			if err == nil {
+				err = fh.rename(fh.fileName, fmt.Sprintf("%s.%s", fh.fileName,
+					common.TimeFormat(fh.clock.Now().Add(0-time.Duration(1)*time.Hour*24), 1)))
+			}
 		}
 	} else {
-		os.Remove(fh.fileName)
+		err = fh.remove(fh.fileName)
 	}
 
-	fh.fd, _ = os.OpenFile(fh.fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if openErr := fh.open(); openErr != nil {
+		// writes fail until the file can be reopened
+		return openErr
+	}
+	fh.stats.rotated(err)
+	return nil
 }
 
 // NewFileHandler new FileHandler
 func NewFileHandler(path, fileName, rotate string, backupCount int, logSize ...int) (*FileHandler, error) {
-	if !common.Exists(path) {
-		if err := os.MkdirAll(path, 0777); err != nil {
+	return NewFileHandlerFS(nil, nil, path, fileName, rotate, backupCount, logSize...)
+}
+
+// NewFileHandlerFS new FileHandler on fs with rotation times from clock,
+// nil fs or clock means the real file system or the system clock
+func NewFileHandlerFS(fs FileSystem, clock Clock, path, fileName, rotate string, backupCount int,
+	logSize ...int) (*FileHandler, error) {
+	if fs == nil {
+		fs = osFileSystem{}
+	}
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	if _, err := fs.Stat(path); err != nil {
+		if err := fs.MkdirAll(path, 0777); err != nil {
 			return nil, err
 		}
 	}
 
 	fileName = path + constants.PathSeparator + fileName
-	fd, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		return nil, err
-	}
 	size := 200 << 20 // default 200M
 	if len(logSize) > 0 {
 		size = logSize[0]
 	}
-	return &FileHandler{fd: fd, fileName: fileName, rotate: NewRotate(rotate),
-		maxBytes: size, backupCount: backupCount, lock: new(sync.Mutex)}, nil
+	fh := &FileHandler{fs: fs, clock: clock, fileName: fileName, rotate: NewRotate(rotate),
+		maxBytes: size, backupCount: backupCount, lock: new(sync.Mutex),
+		stats: metrics.register(fileName)}
+	if err := fh.open(); err != nil {
+		return nil, err
+	}
+	return fh, nil
 }
 
 // NewLogger new a logger
@@ -256,10 +408,39 @@ func NewLogger(name, appender, level string, args ...interface{}) (*Logger, erro
 		output = os.Stdout
 	}
 
-	lg := log.New(output, "", log.LstdFlags|log.Lshortfile)
+	return &Logger{appender: a, level: NewLevel(level), format: TEXT, callerFormat: SHORTFILE,
+		output: output, lock: new(sync.Mutex), fileHandler: fileHandler, name: name,
+		entries: metrics.logger(name)}, nil
+}
+
+// SetFormat set log entry format, TEXT or JSON
+func (l *Logger) SetFormat(format Format) {
+	l.format = format
+}
+
+// SetCallerFormat set caller format, NOCALLER disables the caller lookup
+func (l *Logger) SetCallerFormat(callerFormat CallerFormat) {
+	l.callerFormat = callerFormat
+}
 
-	return &Logger{appender: a, level: NewLevel(level),
-		logger: lg, fileHandler: fileHandler, name: name}, nil
+// WithCallerSkip return a logger sharing the output of l, reporting the caller n frames higher,
+// for loggers used inside helper functions
+func (l *Logger) WithCallerSkip(n int) *Logger {
+	nl := *l
+	nl.callerSkip += n
+	return &nl
+}
+
+// caller look up the caller skip frames above Logger.write
+func (l *Logger) caller(e *entry, skip int) {
+	if l.callerFormat == NOCALLER {
+		return
+	}
+	e.callerFormat = l.callerFormat
+	var ok bool
+	if e.pc, e.file, e.line, ok = runtime.Caller(skip + 1); !ok {
+		e.file, e.line = "???", 0
+	}
 }
 
 func (l *Logger) sync() {
@@ -268,29 +449,73 @@ func (l *Logger) sync() {
 	}
 }
 
-func (l *Logger) write(level Level, message string) {
-	if level < l.level {
-		return
+// Enabled whether entries of level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// write level must be enabled
+func (l *Logger) write(level Level, message string, err error, fields []Field) {
+	atomic.AddUint64(&l.entries[levelIndex(level)], 1)
+
+	e := entry{time: time.Now(), level: level, logger: l.name, message: message, errField: -1}
+	if err == nil {
+		e.errField, err = fieldsError(fields)
+	}
+	if err != nil {
+		// capture stack at the caller of Error etc. for errors without their own
+		e.err = newErrorInfo(err, level >= ERROR, callerDepth+l.callerSkip)
 	}
-	l.logger.SetPrefix(fmt.Sprintf("[%s][%s]", l.name, level.String()))
-	l.logger.Output(2, message)
+	l.caller(&e, callerDepth+l.callerSkip)
+
+	enc := getEncoder()
+	defer putEncoder(enc)
+	if l.format == JSON {
+		if enc.encodeJSON(&e, fields) != nil {
+			return
+		}
+	} else {
+		enc.encodeText(&e, fields)
+	}
+
+	l.lock.Lock()
+	l.output.Write(enc.buf)
+	l.lock.Unlock()
 }
 
-func (l *Logger) output(level Level, v ...interface{}) {
-	l.write(level, fmt.Sprint(v...))
+func (l *Logger) exit(level Level) {
 	if level == FATAL {
 		l.sync()
 		os.Exit(1)
 	}
 }
 
+func (l *Logger) outputLog(level Level, v ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.write(level, fmt.Sprint(v...), findError(v), nil)
+	l.exit(level)
+}
+
 func (l *Logger) outputf(level Level, format string, v ...interface{}) {
-	l.write(level, fmt.Sprintf(format, v...))
+	if !l.Enabled(level) {
+		return
+	}
+	l.write(level, fmt.Sprintf(format, v...), findError(v), nil)
+}
+
+func (l *Logger) outputw(level Level, message string, fields []Field) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.write(level, message, nil, fields)
+	l.exit(level)
 }
 
 // Trace log
 func (l *Logger) Trace(v ...interface{}) {
-	l.output(TRACE, v...)
+	l.outputLog(TRACE, v...)
 }
 
 // Tracef log
@@ -298,9 +523,14 @@ func (l *Logger) Tracef(format string, v ...interface{}) {
 	l.outputf(TRACE, format, v...)
 }
 
+// Tracew log with typed fields
+func (l *Logger) Tracew(message string, fields ...Field) {
+	l.outputw(TRACE, message, fields)
+}
+
 // Debug log
 func (l *Logger) Debug(v ...interface{}) {
-	l.output(DEBUG, v...)
+	l.outputLog(DEBUG, v...)
 }
 
 // Debugf log
@@ -308,9 +538,14 @@ func (l *Logger) Debugf(format string, v ...interface{}) {
 	l.outputf(DEBUG, format, v...)
 }
 
+// Debugw log with typed fields
+func (l *Logger) Debugw(message string, fields ...Field) {
+	l.outputw(DEBUG, message, fields)
+}
+
 // Info log
 func (l *Logger) Info(v ...interface{}) {
-	l.output(INFO, v...)
+	l.outputLog(INFO, v...)
 }
 
 // Infof log
@@ -318,9 +553,14 @@ func (l *Logger) Infof(format string, v ...interface{}) {
 	l.outputf(INFO, format, v...)
 }
 
+// Infow log with typed fields
+func (l *Logger) Infow(message string, fields ...Field) {
+	l.outputw(INFO, message, fields)
+}
+
 // Warning log
 func (l *Logger) Warning(v ...interface{}) {
-	l.output(WARNING, v...)
+	l.outputLog(WARNING, v...)
 }
 
 // Warningf log
@@ -328,21 +568,36 @@ func (l *Logger) Warningf(format string, v ...interface{}) {
 	l.outputf(WARNING, format, v...)
 }
 
+// Warningw log with typed fields
+func (l *Logger) Warningw(message string, fields ...Field) {
+	l.outputw(WARNING, message, fields)
+}
+
 func (l *Logger) Error(v ...interface{}) {
-	l.output(ERROR, v...)
+	l.outputLog(ERROR, v...)
 }
 
-//Errorf log
+// Errorf log
 func (l *Logger) Errorf(format string, v ...interface{}) {
 	l.outputf(ERROR, format, v...)
 }
 
+// Errorw log with typed fields
+func (l *Logger) Errorw(message string, fields ...Field) {
+	l.outputw(ERROR, message, fields)
+}
+
 // Fatal log
 func (l *Logger) Fatal(v ...interface{}) {
-	l.output(FATAL, v...)
+	l.outputLog(FATAL, v...)
 }
 
 // Fatalf log
 func (l *Logger) Fatalf(format string, v ...interface{}) {
 	l.outputf(FATAL, format, v...)
 }
+
+// Fatalw log with typed fields
+func (l *Logger) Fatalw(message string, fields ...Field) {
+	l.outputw(FATAL, message, fields)
+}
@@ -0,0 +1,243 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFS in memory FileSystem for testing FileHandler rotation and retention,
+// modification times come from its Clock
+type MemFS struct {
+	clock Clock
+	lock  sync.Mutex
+	files map[string]*memNode
+	dirs  map[string]bool
+}
+
+type memNode struct {
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemFS new in memory file system, clock nil means the system clock
+func NewMemFS(clock Clock) *MemFS {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &MemFS{clock: clock, files: make(map[string]*memNode),
+		dirs: map[string]bool{".": true, "/": true}}
+}
+
+func memPath(name string) string {
+	return path.Clean(strings.Replace(name, "\\", "/", -1))
+}
+
+// OpenFile open a file, os.O_CREATE, os.O_TRUNC and os.O_APPEND are supported
+func (m *MemFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	name = memPath(name)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	n, ok := m.files[name]
+	if !ok {
+		if flag&os.O_CREATE == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		if !m.dirs[path.Dir(name)] {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		n = &memNode{modTime: m.clock.Now()}
+		m.files[name] = n
+	} else if flag&os.O_TRUNC != 0 {
+		n.data = nil
+		n.modTime = m.clock.Now()
+	}
+
+	return &memFile{fs: m, name: name, node: n}, nil
+}
+
+// Open open a file for reading, later writes are not seen by the reader
+func (m *MemFS) Open(name string) (io.ReadCloser, error) {
+	b, err := m.ReadFile(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: memPath(name), Err: os.ErrNotExist}
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+// ReadDir file info of the files and directories in dir, sorted by name
+func (m *MemFS) ReadDir(dir string) ([]os.FileInfo, error) {
+	dir = memPath(dir)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.dirs[dir] {
+		return nil, &os.PathError{Op: "open", Path: dir, Err: os.ErrNotExist}
+	}
+	var infos []os.FileInfo
+	for name, n := range m.files {
+		if path.Dir(name) == dir {
+			infos = append(infos, &memFileInfo{name: path.Base(name), size: int64(len(n.data)), modTime: n.modTime})
+		}
+	}
+	for name := range m.dirs {
+		if name != dir && path.Dir(name) == dir {
+			infos = append(infos, &memFileInfo{name: path.Base(name), dir: true})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// Rename rename a file, open files keep writing to it under the new name
+func (m *MemFS) Rename(oldPath, newPath string) error {
+	oldPath, newPath = memPath(oldPath), memPath(newPath)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	n, ok := m.files[oldPath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrNotExist}
+	}
+	if m.dirs[newPath] {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrExist}
+	}
+	delete(m.files, oldPath)
+	m.files[newPath] = n
+	return nil
+}
+
+// Remove remove a file
+func (m *MemFS) Remove(name string) error {
+	name = memPath(name)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.files[name]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+// Stat file info of a file or directory
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	name = memPath(name)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if n, ok := m.files[name]; ok {
+		return &memFileInfo{name: path.Base(name), size: int64(len(n.data)), modTime: n.modTime}, nil
+	}
+	if m.dirs[name] {
+		return &memFileInfo{name: path.Base(name), dir: true}, nil
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+// MkdirAll create a directory and its parents
+func (m *MemFS) MkdirAll(dir string, perm os.FileMode) error {
+	dir = memPath(dir)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for ; !m.dirs[dir]; dir = path.Dir(dir) {
+		m.dirs[dir] = true
+	}
+	return nil
+}
+
+// ReadFile content of a file
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	name = memPath(name)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	n, ok := m.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "read", Path: name, Err: os.ErrNotExist}
+	}
+	return append([]byte(nil), n.data...), nil
+}
+
+// Files names of all files, sorted
+func (m *MemFS) Files() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type memFile struct {
+	fs     *MemFS
+	name   string
+	node   *memNode
+	closed bool
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	f.fs.lock.Lock()
+	defer f.fs.lock.Unlock()
+
+	if f.closed {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: os.ErrClosed}
+	}
+	f.node.data = append(f.node.data, b...)
+	f.node.modTime = f.fs.clock.Now()
+	return len(b), nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.lock.Lock()
+	defer f.fs.lock.Unlock()
+
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.lock.Lock()
+	defer f.fs.lock.Unlock()
+
+	return &memFileInfo{name: path.Base(f.name), size: int64(len(f.node.data)),
+		modTime: f.node.modTime}, nil
+}
+
+func (f *memFile) Sync() error {
+	return nil
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi *memFileInfo) Name() string       { return fi.name }
+func (fi *memFileInfo) Size() int64        { return fi.size }
+func (fi *memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *memFileInfo) IsDir() bool        { return fi.dir }
+func (fi *memFileInfo) Sys() interface{}   { return nil }
+
+func (fi *memFileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0777
+	}
+	return 0666
+}
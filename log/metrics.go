@@ -0,0 +1,263 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// levels all log levels, from low to high
+var levels = []Level{TRACE, DEBUG, INFO, WARNING, ERROR, FATAL}
+
+func levelIndex(level Level) int {
+	i := bits.TrailingZeros(uint(level))
+	if i >= len(levels) {
+		return 0
+	}
+	return i
+}
+
+type levelCounters [6]uint64
+
+// handlerCounters FileHandler counters
+type handlerCounters struct {
+	bytesWritten   uint64
+	writes         uint64
+	writeErrors    uint64
+	rotations      uint64
+	rotationErrors uint64
+	lock           sync.Mutex
+	lastError      string
+	writeFailed    bool
+	rotateFailed   bool
+}
+
+func (c *handlerCounters) written(n int, err error) {
+	atomic.AddUint64(&c.bytesWritten, uint64(n))
+	atomic.AddUint64(&c.writes, 1)
+	if err != nil {
+		atomic.AddUint64(&c.writeErrors, 1)
+	}
+	c.lock.Lock()
+	c.writeFailed = err != nil
+	c.setError(err)
+	c.lock.Unlock()
+}
+
+func (c *handlerCounters) rotated(err error) {
+	if err != nil {
+		atomic.AddUint64(&c.rotationErrors, 1)
+	} else {
+		atomic.AddUint64(&c.rotations, 1)
+	}
+	c.lock.Lock()
+	c.rotateFailed = err != nil
+	c.setError(err)
+	c.lock.Unlock()
+}
+
+// setError c.lock must be held
+func (c *handlerCounters) setError(err error) {
+	if err != nil {
+		c.lastError = err.Error()
+	}
+}
+
+// registry log counters of the process, handlers are the counters of open FileHandlers and their file names
+type registry struct {
+	lock     sync.RWMutex
+	entries  map[string]*levelCounters
+	handlers map[*handlerCounters]string
+}
+
+var metrics = &registry{entries: make(map[string]*levelCounters),
+	handlers: make(map[*handlerCounters]string)}
+
+func (r *registry) logger(name string) *levelCounters {
+	r.lock.RLock()
+	c, ok := r.entries[name]
+	r.lock.RUnlock()
+	if ok {
+		return c
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if c, ok = r.entries[name]; !ok {
+		c = new(levelCounters)
+		r.entries[name] = c
+	}
+	return c
+}
+
+// register new counters of a FileHandler of fileName
+func (r *registry) register(fileName string) *handlerCounters {
+	c := new(handlerCounters)
+	r.lock.Lock()
+	r.handlers[c] = fileName
+	r.lock.Unlock()
+	return c
+}
+
+// unregister counters of a closed FileHandler
+func (r *registry) unregister(c *handlerCounters) {
+	r.lock.Lock()
+	delete(r.handlers, c)
+	r.lock.Unlock()
+}
+
+// HandlerMetrics FileHandler counters and health, writes are synchronous so no entry is dropped
+type HandlerMetrics struct {
+	BytesWritten   uint64
+	Writes         uint64
+	WriteErrors    uint64
+	Rotations      uint64
+	RotationErrors uint64
+	// Healthy last write and last rotation succeeded
+	Healthy   bool
+	LastError string
+}
+
+func (c *handlerCounters) snapshot() HandlerMetrics {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return HandlerMetrics{
+		BytesWritten:   atomic.LoadUint64(&c.bytesWritten),
+		Writes:         atomic.LoadUint64(&c.writes),
+		WriteErrors:    atomic.LoadUint64(&c.writeErrors),
+		Rotations:      atomic.LoadUint64(&c.rotations),
+		RotationErrors: atomic.LoadUint64(&c.rotationErrors),
+		Healthy:        !c.writeFailed && !c.rotateFailed,
+		LastError:      c.lastError,
+	}
+}
+
+// add counters of another handler of the same file, first if h is empty
+func (h HandlerMetrics) add(o HandlerMetrics, first bool) HandlerMetrics {
+	h.BytesWritten += o.BytesWritten
+	h.Writes += o.Writes
+	h.WriteErrors += o.WriteErrors
+	h.Rotations += o.Rotations
+	h.RotationErrors += o.RotationErrors
+	h.Healthy = (first || h.Healthy) && o.Healthy
+	if o.LastError != "" {
+		h.LastError = o.LastError
+	}
+	return h
+}
+
+// Metrics snapshot of log counters
+type Metrics struct {
+	// Entries logger name -> level -> entries
+	Entries map[string]map[Level]uint64
+	// Levels level -> entries of all loggers
+	Levels map[Level]uint64
+	// Handlers file name -> counters of the open FileHandlers of the file
+	Handlers map[string]HandlerMetrics
+}
+
+// ReadMetrics return counters of all loggers and file handlers
+func ReadMetrics() Metrics {
+	m := Metrics{Entries: make(map[string]map[Level]uint64),
+		Levels: make(map[Level]uint64), Handlers: make(map[string]HandlerMetrics)}
+
+	metrics.lock.RLock()
+	defer metrics.lock.RUnlock()
+	for name, c := range metrics.entries {
+		entries := make(map[Level]uint64, len(levels))
+		for i, level := range levels {
+			n := atomic.LoadUint64(&c[i])
+			entries[level] = n
+			m.Levels[level] += n
+		}
+		m.Entries[name] = entries
+	}
+	for c, fileName := range metrics.handlers {
+		h, ok := m.Handlers[fileName]
+		m.Handlers[fileName] = h.add(c.snapshot(), !ok)
+	}
+
+	return m
+}
+
+// MetricsHandler http handler output counters in prometheus text format
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w)
+	})
+}
+
+// WriteMetrics write counters in prometheus text format
+func WriteMetrics(w io.Writer) error {
+	m := ReadMetrics()
+
+	names := make([]string, 0, len(m.Entries))
+	for name := range m.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	files := make([]string, 0, len(m.Handlers))
+	for fileName := range m.Handlers {
+		files = append(files, fileName)
+	}
+	sort.Strings(files)
+
+	var b strings.Builder
+	writeHeader(&b, "gotools_log_entries_total", "counter", "Log entries by logger and level.")
+	for _, name := range names {
+		for _, level := range levels {
+			fmt.Fprintf(&b, "gotools_log_entries_total{logger=\"%s\",level=\"%s\"} %d\n",
+				escapeLabel(name), level, m.Entries[name][level])
+		}
+	}
+
+	handlerMetrics := []struct {
+		name  string
+		typ   string
+		help  string
+		value func(HandlerMetrics) uint64
+	}{
+		{"gotools_log_handler_bytes_written_total", "counter", "Bytes written to the log file.",
+			func(h HandlerMetrics) uint64 { return h.BytesWritten }},
+		{"gotools_log_handler_writes_total", "counter", "Writes to the log file.",
+			func(h HandlerMetrics) uint64 { return h.Writes }},
+		{"gotools_log_handler_write_errors_total", "counter", "Failed writes to the log file.",
+			func(h HandlerMetrics) uint64 { return h.WriteErrors }},
+		{"gotools_log_handler_rotations_total", "counter", "Log file rotations.",
+			func(h HandlerMetrics) uint64 { return h.Rotations }},
+		{"gotools_log_handler_rotation_errors_total", "counter", "Failed log file rotations.",
+			func(h HandlerMetrics) uint64 { return h.RotationErrors }},
+		{"gotools_log_handler_up", "gauge", "1 if the last write and last rotation succeeded.",
+			func(h HandlerMetrics) uint64 {
+				if h.Healthy {
+					return 1
+				}
+				return 0
+			}},
+	}
+	for _, hm := range handlerMetrics {
+		writeHeader(&b, hm.name, hm.typ, hm.help)
+		for _, fileName := range files {
+			fmt.Fprintf(&b, "%s{file=\"%s\"} %d\n", hm.name, escapeLabel(fileName), hm.value(m.Handlers[fileName]))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeHeader(b *strings.Builder, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
@@ -0,0 +1,200 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+// Record a parsed log entry
+type Record struct {
+	Time    time.Time
+	Level   Level
+	Logger  string
+	Caller  string
+	Message string
+	// Fields key=value pairs in text messages, extra keys in json entries
+	Fields map[string]string
+	// Raw original line(s)
+	Raw string
+}
+
+var (
+	// [name][LEVEL]2006/01/02 15:04:05 file.go:12: message
+	textRecordRegexp = regexp.MustCompile(
+		`^\[([^\]]*)\]\[([A-Z]+)\](\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?) (?:(\S+:\d+): )?(.*)$`)
+	textFieldRegexp = regexp.MustCompile(`([A-Za-z_][\w.\-]*)=("(?:[^"\\]|\\.)*"|\S+)`)
+	// file.log.1, file.log.2006-01-02, optionally gzipped
+	backupSuffixRegexp = regexp.MustCompile(`^\.(?:(\d+)|(\d{4}-\d{2}-\d{2}))(?:\.gz)?$`)
+)
+
+// json entry keys
+const (
+	jsonKeyTime    = "time"
+	jsonKeyLevel   = "level"
+	jsonKeyLogger  = "logger"
+	jsonKeyCaller  = "caller"
+	jsonKeyMessage = "msg"
+)
+
+// ParseRecord parse a text or json log line
+// return false if line is not the beginning of an entry, e.g. the continuation of a multi-line message
+func ParseRecord(line string) (*Record, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "{") {
+		return parseJSONRecord(line)
+	}
+
+	m := textRecordRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	t, err := time.ParseInLocation("2006/01/02 15:04:05", m[3], time.Local)
+	if err != nil {
+		return nil, false
+	}
+
+	return &Record{Time: t, Level: NewLevel(m[2]), Logger: m[1], Caller: m[4],
+		Message: m[5], Fields: parseTextFields(m[5]), Raw: line}, true
+}
+
+// Append add a continuation line to the record
+func (r *Record) Append(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	r.Message += "\n" + line
+	r.Raw += "\n" + line
+	for k, v := range parseTextFields(line) {
+		if _, ok := r.Fields[k]; !ok {
+			r.Fields[k] = v
+		}
+	}
+}
+
+func parseTextFields(message string) map[string]string {
+	fields := make(map[string]string)
+	for _, m := range textFieldRegexp.FindAllStringSubmatch(message, -1) {
+		v := m[2]
+		if strings.HasPrefix(v, `"`) {
+			if s, err := strconv.Unquote(v); err == nil {
+				v = s
+			}
+		}
+		fields[m[1]] = v
+	}
+
+	return fields
+}
+
+func parseJSONRecord(line string) (*Record, bool) {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return nil, false
+	}
+
+	r := &Record{Fields: make(map[string]string), Raw: line}
+	for k, v := range m {
+		var s string
+		switch val := v.(type) {
+		case string:
+			s = val
+		case nil:
+			s = ""
+		case float64, bool:
+			s = fmt.Sprint(val)
+		default:
+			b, _ := json.Marshal(val)
+			s = string(b)
+		}
+
+		switch k {
+		case jsonKeyTime:
+			r.Time, _ = common.StrToTime(s)
+		case jsonKeyLevel:
+			r.Level = NewLevel(s)
+		case jsonKeyLogger:
+			r.Logger = s
+		case jsonKeyCaller:
+			r.Caller = s
+		case jsonKeyMessage:
+			r.Message = s
+		default:
+			r.Fields[k] = s
+		}
+	}
+
+	return r, true
+}
+
+type backupFile struct {
+	name  string
+	index int
+	date  string
+}
+
+// BackupFiles list the log file and its FileHandler backups, oldest first
+// name.2006-01-02 (daily) and name.N (size) backups are included, also gzipped ones
+func BackupFiles(fileName string) ([]string, error) {
+	return backupFiles(osFileSystem{}, fileName)
+}
+
+func backupFiles(fs FileSystem, fileName string) ([]string, error) {
+	dir, base := filepath.Split(fileName)
+	if dir == "" {
+		dir = "."
+	}
+	infos, err := fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backupFile
+	var current string
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, base) {
+			continue
+		}
+		if name == base {
+			current = filepath.Join(dir, name)
+			continue
+		}
+		m := backupSuffixRegexp.FindStringSubmatch(name[len(base):])
+		if m == nil {
+			continue
+		}
+		b := backupFile{name: filepath.Join(dir, name), date: m[2]}
+		if m[1] != "" {
+			b.index, _ = strconv.Atoi(m[1])
+		}
+		backups = append(backups, b)
+	}
+
+	// dated backups by date, then numbered backups from the highest index
+	sort.Slice(backups, func(i, j int) bool {
+		bi, bj := backups[i], backups[j]
+		if bi.date != "" || bj.date != "" {
+			if bi.date == "" || bj.date == "" {
+				return bi.date != ""
+			}
+			return bi.date < bj.date
+		}
+		return bi.index > bj.index
+	})
+
+	files := make([]string, 0, len(backups)+1)
+	for _, b := range backups {
+		files = append(files, b.name)
+	}
+	if current != "" {
+		files = append(files, current)
+	}
+
+	return files, nil
+}
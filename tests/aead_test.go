@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+func TestAEAD(t *testing.T) {
+	text := []byte("card 4111 1111 1111 1111")
+	ad := []byte("user:42")
+
+	for _, algorithm := range []common.AEADAlgorithm{common.AES256GCM, common.ChaCha20Poly1305} {
+		key, err := common.NewAEADKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := common.NewAEAD(algorithm, 1, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := a.Encrypt(text, ad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b[0] != 1 || b[1] != 1 || len(b) != 2+12+len(text)+16 {
+			t.Errorf("algorithm %d: header error % x", algorithm, b[:2])
+		}
+		if plain, err := a.Decrypt(b, ad); err != nil || !bytes.Equal(plain, text) {
+			t.Errorf("algorithm %d: decrypt error %v", algorithm, err)
+		}
+		if b2, _ := a.Encrypt(text, ad); bytes.Equal(b, b2) {
+			t.Errorf("algorithm %d: nonce reused", algorithm)
+		}
+
+		if _, err := a.Decrypt(b, []byte("user:43")); !errors.Is(err, common.ErrDecrypt) {
+			t.Errorf("algorithm %d: decrypted with other additional data: %v", algorithm, err)
+		}
+		for _, i := range []int{0, 1, 5, len(b) - 1} {
+			tampered := append([]byte(nil), b...)
+			tampered[i] ^= 1
+			if _, err := a.Decrypt(tampered, ad); !errors.Is(err, common.ErrDecrypt) {
+				t.Errorf("algorithm %d: decrypted with byte %d changed: %v", algorithm, i, err)
+			}
+		}
+		if _, err := a.Decrypt(b[:10], ad); !errors.Is(err, common.ErrDecrypt) {
+			t.Errorf("algorithm %d: decrypted a short ciphertext: %v", algorithm, err)
+		}
+
+		s, err := a.EncryptBase64(text, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain, err := a.DecryptBase64(s, nil); err != nil || !bytes.Equal(plain, text) {
+			t.Errorf("algorithm %d: base64 error %v", algorithm, err)
+		}
+		s, err = a.EncryptURLBase64(text, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.ContainsAny(s, "+/=") {
+			t.Errorf("algorithm %d: not url safe: %s", algorithm, s)
+		}
+		if plain, err := a.DecryptURLBase64(s, nil); err != nil || !bytes.Equal(plain, text) {
+			t.Errorf("algorithm %d: url base64 error %v", algorithm, err)
+		}
+	}
+
+	if _, err := common.NewAEAD(common.AES256GCM, 1, []byte("short")); err == nil {
+		t.Error("short key accepted")
+	}
+}
+
+func TestAEADRotation(t *testing.T) {
+	oldKey, _ := common.NewAEADKey()
+	newKey, _ := common.NewAEADKey()
+	text := []byte("secret")
+
+	old, _ := common.NewAEAD(common.AES256GCM, 1, oldKey)
+	b, _ := old.Encrypt(text, nil)
+
+	rotated, err := common.NewAEAD(common.ChaCha20Poly1305, 2, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotated.Decrypt(b, nil); !errors.Is(err, common.ErrDecrypt) {
+		t.Errorf("decrypted with an unknown key: %v", err)
+	}
+	if err := rotated.AddKey(common.AES256GCM, 1, oldKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := rotated.AddKey(common.AES256GCM, 2, oldKey); err == nil {
+		t.Error("key id added twice")
+	}
+	if plain, err := rotated.Decrypt(b, nil); err != nil || !bytes.Equal(plain, text) {
+		t.Errorf("old key decrypt error: %v", err)
+	}
+	b, _ = rotated.Encrypt(text, nil)
+	if b[1] != 2 {
+		t.Errorf("encrypted with key %d", b[1])
+	}
+	if _, err := old.Decrypt(b, nil); !errors.Is(err, common.ErrDecrypt) {
+		t.Errorf("old aead decrypted the new key: %v", err)
+	}
+}
+
+func TestURLBase64(t *testing.T) {
+	b := []byte{0xfb, 0xff, 0xfe}
+	s := common.URLBase64Encode(b)
+	if s != "-__-" {
+		t.Errorf("url base64 encode: %s", s)
+	}
+	for _, encoded := range []string{"-__-", "-_8", "-_8="} {
+		if _, err := common.URLBase64Decode(encoded); err != nil {
+			t.Errorf("url base64 decode %s: %v", encoded, err)
+		}
+	}
+}
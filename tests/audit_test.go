@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kbrownehs18/gotools/log"
+)
+
+func writeAuditLog(t *testing.T, dir string, entries int) {
+	fileHandler, err := log.NewFileHandler(dir, "audit.log", "size", 10, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileHandler.Close()
+	audit, err := log.NewAuditLogger(fileHandler, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < entries; i++ {
+		if err := audit.Log("user login", log.String("user", "bob"), log.Int("i", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "audit.log")
+
+	writeAuditLog(t, dir, 6)
+	// a new logger continues the chain
+	writeAuditLog(t, dir, 4)
+
+	result, err := log.VerifyAudit(fileName, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Broken != nil || result.Entries != 10 || len(result.Files) < 2 || result.FirstSeq != 1 || result.LastSeq != 10 {
+		t.Fatalf("verify error: %+v %v", result, result.Broken)
+	}
+
+	result, err = log.VerifyAudit(fileName, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Broken == nil || result.Broken.Line != 1 {
+		t.Errorf("wrong key not detected: %+v", result)
+	}
+
+	// remove the second entry of the oldest backup
+	oldest := result.Files[0]
+	b, err := ioutil.ReadFile(oldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	if err := ioutil.WriteFile(oldest, []byte(lines[0]+strings.Join(lines[2:], "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = log.VerifyAudit(fileName, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Broken == nil || result.Broken.Seq != 3 || !strings.Contains(result.Broken.Reason, "prev mac") {
+		t.Errorf("removed entry not detected: %+v", result.Broken)
+	}
+
+	// modify an entry
+	modified := strings.Replace(lines[0], `"user":"bob"`, `"user":"eve"`, 1)
+	if err := ioutil.WriteFile(oldest, []byte(modified+strings.Join(lines[1:], "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = log.VerifyAudit(fileName, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Broken == nil || result.Broken.Line != 1 || !strings.Contains(result.Broken.Reason, "mac mismatch") {
+		t.Errorf("modified entry not detected: %+v", result.Broken)
+	}
+}
+
+func TestAuditRetention(t *testing.T) {
+	writeAudit := func(fs *log.MemFS, backupCount, entries int) {
+		fileHandler, err := log.NewFileHandlerFS(fs, nil, "logs", "audit.log", "size", backupCount, 300)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fileHandler.Close()
+		audit, err := log.NewAuditLogger(fileHandler, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < entries; i++ {
+			if err := audit.Log("user login", log.String("user", "bob"), log.Int("i", i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// old backups are removed, the chain starts after the checkpoint
+	for _, backupCount := range []int{0, 2} {
+		fs := log.NewMemFS(nil)
+		writeAudit(fs, backupCount, 20)
+		writeAudit(fs, backupCount, 5)
+		result, err := log.VerifyAuditFS(fs, "logs/audit.log", "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Broken != nil || result.Checkpoint == 0 || result.FirstSeq != result.Checkpoint+1 || result.LastSeq != 25 {
+			t.Fatalf("backupCount %d: %+v %v", backupCount, result, result.Broken)
+		}
+	}
+
+	// the oldest backup removed without the rotation
+	fs := log.NewMemFS(nil)
+	writeAudit(fs, 3, 20)
+	result, _ := log.VerifyAuditFS(fs, "logs/audit.log", "secret")
+	if result.Broken != nil || len(result.Files) != 4 {
+		t.Fatalf("verify error: %+v %v", result, result.Broken)
+	}
+	fs.Remove(result.Files[0])
+	result, err := log.VerifyAuditFS(fs, "logs/audit.log", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Broken == nil || !strings.Contains(result.Broken.Reason, "chain starts at seq") {
+		t.Errorf("removed backup not detected: %+v", result.Broken)
+	}
+
+	// without the checkpoint the chain must start at seq 1
+	writeAudit(fs, 3, 10)
+	fs.Remove("logs/audit.log.checkpoint")
+	result, _ = log.VerifyAuditFS(fs, "logs/audit.log", "secret")
+	if result.Broken == nil || result.Broken.Line != 1 || result.Broken.Seq == 1 {
+		t.Errorf("removed checkpoint not detected: %+v", result.Broken)
+	}
+}
+
+func TestAuditDailyRetention(t *testing.T) {
+	// quiet days leave older backups the daily retention does not remove
+	now := time.Date(2026, 10, 1, 10, 0, 0, 0, time.Local)
+	clock := log.ClockFunc(func() time.Time { return now })
+	fs := log.NewMemFS(clock)
+	fileHandler, err := log.NewFileHandlerFS(fs, clock, "logs", "audit.log", "daily", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileHandler.Close()
+	audit, err := log.NewAuditLogger(fileHandler, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, day := range []int{0, 2, 4, 5, 6, 7} {
+		now = time.Date(2026, 10, 1+day, 10, 0, 0, 0, time.Local)
+		for i := 0; i < 3; i++ {
+			if err := audit.Log("user login", log.Int("day", day)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	result, err := log.VerifyAuditFS(fs, "logs/audit.log", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Broken != nil || result.Checkpoint == 0 || result.FirstSeq != result.Checkpoint+1 ||
+		result.LastSeq != 18 || len(result.Stale) == 0 {
+		t.Errorf("verify error: %+v %v", result, result.Broken)
+	}
+}
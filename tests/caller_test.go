@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/log"
+)
+
+// logHelper a wrapper of the logger, like an application's own log helper
+func logHelper(logger *log.Logger, message string) {
+	logger.WithCallerSkip(1).Error(message)
+}
+
+func TestCaller(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logger, read := newFileLogger(t, dir, log.TEXT)
+
+	_, file, line, _ := runtime.Caller(0)
+	logHelper(logger, "wrapped")
+	expected := "caller_test.go:" + strconv.Itoa(line+1) + ": wrapped"
+	if s := read(); !strings.Contains(s, expected) {
+		t.Errorf("expect %s in %s", expected, s)
+	}
+
+	cases := []struct {
+		format   log.CallerFormat
+		expected string
+	}{
+		{log.LONGFILE, file + ":"},
+		{log.FUNCNAME, "tests.TestCaller:"},
+		{log.SHORTFILE, " caller_test.go:"},
+	}
+	for _, c := range cases {
+		logger.SetCallerFormat(c.format)
+		logger.Error("message")
+		if s := read(); !strings.Contains(s, c.expected) {
+			t.Errorf("caller format %d: expect %s in %s", c.format, c.expected, s)
+		}
+	}
+
+	logger.SetCallerFormat(log.NOCALLER)
+	logger.Error("message")
+	r, ok := log.ParseRecord(read())
+	if !ok || r.Caller != "" || r.Message != "message" {
+		t.Errorf("no caller record error: %+v", r)
+	}
+}
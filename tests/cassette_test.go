@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+func TestCassette(t *testing.T) {
+	var n int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		if r.URL.Path == "/binary" {
+			w.Write([]byte{0xff, 0x00, 0xfe})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(r.Method + " " + string(body) + " " + strings.Repeat("!", n)))
+	}))
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	send := func(client *http.Client) []*common.Response {
+		var responses []*common.Response
+		for _, r := range []*common.Request{
+			common.NewRequest(server.URL+"/a").Method(common.POST).Header("Authorization", "Bearer t0k").Body([]byte("one"), "text/plain"),
+			common.NewRequest(server.URL+"/a").Method(common.POST).Body([]byte("two"), "text/plain"),
+			common.NewRequest(server.URL + "/binary"),
+		} {
+			res, err := r.Client(client).Do(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			responses = append(responses, res)
+		}
+		return responses
+	}
+
+	cassette, err := common.NewCassette(path, common.ModeOnce)
+	if err != nil || !cassette.Recording() {
+		t.Fatal("cassette should record", err)
+	}
+	recorded := send(&http.Client{Transport: cassette})
+	server.Close()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "t0k") || strings.Contains(string(b), "session=secret") || !strings.Contains(string(b), common.Redacted) {
+		t.Errorf("headers not redacted: %s", b)
+	}
+
+	cassette, err = common.NewCassette(path, common.ModeOnce)
+	if err != nil || cassette.Recording() {
+		t.Fatal("cassette should replay", err)
+	}
+	replayed := send(&http.Client{Transport: cassette})
+	for i := range recorded {
+		if replayed[i].StatusCode != recorded[i].StatusCode || replayed[i].String() != recorded[i].String() {
+			t.Errorf("replay %d error: %+v, recorded %+v", i, replayed[i], recorded[i])
+		}
+	}
+	if replayed[0].String() != "POST one !" || replayed[2].String() != "\xff\x00\xfe" {
+		t.Errorf("replay error: %q %q", replayed[0], replayed[2])
+	}
+
+	// the last match is replayed again
+	res, err := common.NewRequest(server.URL + "/binary").Client(&http.Client{Transport: cassette}).Do(ctx)
+	if err != nil || res.String() != "\xff\x00\xfe" {
+		t.Errorf("repeat error: %v %v", res, err)
+	}
+
+	_, err = common.NewRequest(server.URL+"/a").Method(common.POST).Body([]byte("three"), "text/plain").
+		Client(&http.Client{Transport: cassette}).Do(ctx)
+	if !errors.Is(err, common.ErrNoInteraction) {
+		t.Errorf("unmatched error: %v", err)
+	}
+
+	cassette.Matcher = common.MatchMethodURL
+	res, err = common.NewRequest(server.URL+"/a").Method(common.POST).Body([]byte("three"), "text/plain").
+		Client(&http.Client{Transport: cassette}).Do(ctx)
+	if err != nil || res.String() != "POST two !!" {
+		t.Errorf("method url matcher error: %v %v", res, err)
+	}
+
+	if _, err = common.NewCassette(filepath.Join(t.TempDir(), "missing.json"), common.ModeReplay); err == nil {
+		t.Error("missing cassette error")
+	}
+}
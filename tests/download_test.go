@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+// fileServer serve content with ranges and an etag, count the bytes sent
+func fileServer(content []byte, sent *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(&countingWriter{ResponseWriter: w, n: sent}, r, "file.bin", time.Unix(0, 0), bytes.NewReader(content))
+	}))
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n *int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	atomic.AddInt64(w.n, int64(len(p)))
+	return w.ResponseWriter.Write(p)
+}
+
+func TestDownload(t *testing.T) {
+	content := make([]byte, 1<<20+123)
+	rand.New(rand.NewSource(1)).Read(content)
+	sum := sha256.Sum256(content)
+	var sent int64
+	server := fileServer(content, &sent)
+	defer server.Close()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "file.bin")
+	var done, total int64
+	n, err := common.NewDownload(server.URL, file).Chunks(4).SHA256(hex.EncodeToString(sum[:])).
+		Progress(func(d, t int64) { done, total = d, t }).Do(ctx)
+	if err != nil || n != int64(len(content)) {
+		t.Fatal(n, err)
+	}
+	if b, _ := ioutil.ReadFile(file); !bytes.Equal(b, content) {
+		t.Error("downloaded content differs")
+	}
+	if done != total || total != int64(len(content)) {
+		t.Errorf("progress error: %d of %d", done, total)
+	}
+	if _, err := os.Stat(file + ".part"); !os.IsNotExist(err) {
+		t.Error("part file left")
+	}
+
+	file = filepath.Join(dir, "bad.bin")
+	_, err = common.NewDownload(server.URL, file).MD5("0123").Do(ctx)
+	if !errors.Is(err, common.ErrChecksum) {
+		t.Errorf("checksum error: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("file with bad checksum created")
+	}
+
+	_, err = common.NewDownload(server.URL+"/missing", filepath.Join(dir, "missing")).Client(&http.Client{
+		Transport: common.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 404, Status: "404 Not Found", Header: http.Header{},
+				Body: ioutil.NopCloser(bytes.NewReader([]byte("no such file")))}, nil
+		}),
+	}).Do(ctx)
+	var httpErr *common.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 404 || string(httpErr.Body) != "no such file" {
+		t.Errorf("not found error: %v", err)
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	content := make([]byte, 8<<20)
+	rand.New(rand.NewSource(2)).Read(content)
+	var sent int64
+	server := fileServer(content, &sent)
+	defer server.Close()
+	file := filepath.Join(t.TempDir(), "file.bin")
+
+	// interrupted after half of the file
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := common.NewDownload(server.URL, file).Chunks(2).Progress(func(done, total int64) {
+		if done > total/2 {
+			cancel()
+		}
+	}).Do(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("interrupt error: %v", err)
+	}
+	if _, err := os.Stat(file + ".part.json"); err != nil {
+		t.Fatal("state not saved", err)
+	}
+
+	first := atomic.LoadInt64(&sent)
+	n, err := common.NewDownload(server.URL, file).Chunks(2).Do(context.Background())
+	if err != nil || n != int64(len(content)) {
+		t.Fatal(n, err)
+	}
+	if b, _ := ioutil.ReadFile(file); !bytes.Equal(b, content) {
+		t.Error("resumed content differs")
+	}
+	if resumed := atomic.LoadInt64(&sent) - first; resumed >= int64(len(content))*3/4 {
+		t.Errorf("resume downloaded %d bytes again", resumed)
+	}
+	if _, err := os.Stat(file + ".part.json"); !os.IsNotExist(err) {
+		t.Error("state file left")
+	}
+}
+
+func TestDownloadStream(t *testing.T) {
+	// no HEAD and no ranges
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write([]byte("streamed"))
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "file.txt")
+	n, err := common.NewDownload(server.URL, file).Chunks(4).MD5(common.Md5Sum("streamed")).Do(context.Background())
+	if b, _ := ioutil.ReadFile(file); err != nil || n != 8 || string(b) != "streamed" {
+		t.Errorf("stream error: %v %d %q", err, n, b)
+	}
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/log"
+)
+
+type stackError struct {
+	pcs []uintptr
+}
+
+func (e *stackError) Error() string {
+	return "stack error"
+}
+
+func (e *stackError) StackTrace() []uintptr {
+	return e.pcs
+}
+
+func newStackError() error {
+	pcs := make([]uintptr, 16)
+	return &stackError{pcs: pcs[:runtime.Callers(1, pcs)]}
+}
+
+func newFileLogger(t *testing.T, dir string, format log.Format) (*log.Logger, func() string) {
+	fileHandler, err := log.NewFileHandler(dir, "error.log", "none", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := log.NewLogger("Errors", "file", "info", fileHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.SetFormat(format)
+
+	return logger, func() string {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "error.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Truncate(filepath.Join(dir, "error.log"), 0)
+		return string(b)
+	}
+}
+
+func TestErrorLogJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logger, read := newFileLogger(t, dir, log.JSON)
+
+	err = fmt.Errorf("save user: %w", errors.Join(os.ErrNotExist, errors.New("disk full")))
+	logger.Error(err)
+
+	var entry struct {
+		Level string
+		Msg   string
+		Error struct {
+			Message string
+			Causes  []struct {
+				Message string
+				Depth   int
+			}
+			Stack []struct {
+				Func string
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(read()), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != "ERROR" || entry.Error.Message != err.Error() {
+		t.Errorf("entry error: %+v", entry)
+	}
+	if len(entry.Error.Causes) != 3 || entry.Error.Causes[1].Message != os.ErrNotExist.Error() ||
+		entry.Error.Causes[2].Depth != 2 {
+		t.Errorf("causes error: %+v", entry.Error.Causes)
+	}
+	if len(entry.Error.Stack) == 0 || !strings.HasSuffix(entry.Error.Stack[0].Func, "TestErrorLogJSON") {
+		t.Errorf("stack error: %+v", entry.Error.Stack)
+	}
+}
+
+func TestErrorLogText(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logger, read := newFileLogger(t, dir, log.TEXT)
+
+	logger.Errorf("load config: %v", fmt.Errorf("wrapped: %w", newStackError()))
+	s := read()
+	for _, line := range []string{
+		"    error: wrapped: stack error (*fmt.wrapError)",
+		"      cause: stack error (*tests.stackError)",
+		"    stack:",
+		"      github.com/kbrownehs18/gotools/tests.newStackError",
+	} {
+		if !strings.Contains(s, line+"\n") {
+			t.Errorf("no line %q in:\n%s", line, s)
+		}
+	}
+
+	logger.Info(errors.New("no stack below error level"))
+	if s = read(); strings.Contains(s, "stack:") {
+		t.Errorf("stack captured for info:\n%s", s)
+	}
+}
@@ -2,6 +2,9 @@ package tests
 
 import (
 	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/kbrownehs18/gotools/common"
@@ -47,22 +50,49 @@ func TestContains(t *testing.T) {
 	t.Log(common.Contains(m, "name"))
 }
 
+// httpCassette replay testdata/name.json, set GOTOOLS_RECORD=1 to record it again from the network
+func httpCassette(t *testing.T, name string) *http.Client {
+	mode := common.ModeReplay
+	if os.Getenv("GOTOOLS_RECORD") == "1" {
+		mode = common.ModeRecord
+	}
+	cassette, err := common.NewCassette(filepath.Join("testdata", name+".json"), mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Transport: cassette}
+}
+
 func TestHttpRequest(t *testing.T) {
+	// hand-written, not a recording: the responses only echo the query, the form and the JSON body
+	client := httpCassette(t, "postman-echo-synthetic")
+	var echo struct {
+		Args map[string]string `json:"args"`
+		Form map[string]string `json:"form"`
+		JSON map[string]string `json:"json"`
+	}
+
 	rtn, err := common.HTTPRequest("https://postman-echo.com/get", common.GET, map[string]string{
 		"foo1": "bar1", "foo2": "bar2",
-	})
+	}, map[string]string{}, true, client)
 	if err != nil {
 		t.Error(err)
 	}
 	t.Log(rtn)
+	if err := json.Unmarshal([]byte(rtn), &echo); err != nil || echo.Args["foo1"] != "bar1" {
+		t.Errorf("get error: %v %v", echo, err)
+	}
 
 	rtn, err = common.HTTPRequest("https://postman-echo.com/post", common.POST, map[string]string{
 		"foo1": "bar1", "foo2": "bar2",
-	})
+	}, map[string]string{}, true, client)
 	if err != nil {
 		t.Error(err)
 	}
 	t.Log(rtn)
+	if err := json.Unmarshal([]byte(rtn), &echo); err != nil || echo.Form["foo2"] != "bar2" {
+		t.Errorf("post error: %v %v", echo, err)
+	}
 
 	str, err := json.Marshal(map[string]string{
 		"foo1": "bar1", "foo2": "bar2",
@@ -70,11 +100,14 @@ func TestHttpRequest(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	rtn, err = common.HTTPRequest("https://postman-echo.com/post", common.POST, string(str))
+	rtn, err = common.HTTPRequest("https://postman-echo.com/post", common.POST, string(str), map[string]string{}, true, client)
 	if err != nil {
 		t.Error(err)
 	}
 	t.Log(rtn)
+	if err := json.Unmarshal([]byte(rtn), &echo); err != nil || echo.JSON["foo1"] != "bar1" {
+		t.Errorf("post json error: %v %v", echo, err)
+	}
 }
 
 func TestAuthcode(t *testing.T) {
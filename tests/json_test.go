@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func TestJSON(t *testing.T) {
+	ctx := context.Background()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", echoHandler)
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"id":1,"name":"bob"}`))
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":"not_found","message":"` + strings.Repeat("x", 500) + `"}`))
+	})
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`"` + strings.Repeat("x", 100) + `"`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	u, err := common.GetJSON[user](ctx, server.URL+"/user")
+	if err != nil || u.ID != 1 || u.Name != "bob" {
+		t.Errorf("get json error: %+v %v", u, err)
+	}
+
+	e, err := common.PostJSON[user, echo](ctx, server.URL+"/echo", user{ID: 2, Name: "amy"})
+	if err != nil || e.Method != "POST" || e.Body != `{"id":2,"name":"amy"}` ||
+		e.ContentType != "application/json; charset=utf-8" {
+		t.Errorf("post json error: %+v %v", e, err)
+	}
+
+	_, err = common.GetJSON[user](ctx, server.URL+"/missing")
+	var httpErr *common.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("http error: %v", err)
+	}
+	var body apiError
+	if err := httpErr.JSON(&body); err != nil || body.Code != "not_found" {
+		t.Errorf("http error body: %+v %v", body, err)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "http 404 Not Found: {") || len(msg) > 300 {
+		t.Errorf("http error message: %s", msg)
+	}
+
+	if _, err = common.GetJSON[user](ctx, server.URL+"/html"); err == nil || !strings.Contains(err.Error(), "text/html") {
+		t.Errorf("content type error: %v", err)
+	}
+
+	_, err = common.DoJSON[string](ctx, common.NewRequest(server.URL+"/big").MaxSize(50))
+	if !errors.Is(err, common.ErrResponseTooLarge) {
+		t.Errorf("size limit error: %v", err)
+	}
+	if s, err := common.DoJSON[string](ctx, common.NewRequest(server.URL+"/big").MaxSize(102)); err != nil || len(s) != 100 {
+		t.Errorf("size limit error: %v", err)
+	}
+}
+
+func TestHttpRequestJSONContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	res, err := common.HTTPRequest(server.URL, common.POST, `{"a":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res, `"content_type":"application/json; charset=utf-8"`) {
+		t.Errorf("content type error: %s", res)
+	}
+}
@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+type userClaims struct {
+	common.RegisteredClaims
+	Role string `json:"role"`
+}
+
+// jwtKeys signing keys of every algorithm with their public keys
+func jwtKeys(t *testing.T) (signing, public []*common.JWTKey) {
+	signing = append(signing, common.NewHMACKey("hs", []byte("0123456789abcdef0123456789abcdef")))
+	public = append(public, signing[0])
+	for i, keyType := range []common.KeyType{common.RSAKey, common.ECDSAP256Key, common.Ed25519Key} {
+		priv, pub, err := common.GenerateKey(keyType, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := string(rune('a' + i))
+		sk, err := common.NewJWTKey(id, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pk, err := common.NewJWTKey(id, pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signing, public = append(signing, sk), append(public, pk)
+	}
+	return signing, public
+}
+
+func TestJWT(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	signing, public := jwtKeys(t)
+	others, _ := jwtKeys(t)
+	others[0] = common.NewHMACKey("hs", []byte("another secret"))
+	v := &common.JWTVerifier{Keys: public, Issuer: "auth", Audience: "api", Now: func() time.Time { return now }}
+
+	for i, key := range signing {
+		claims := userClaims{common.RegisteredClaims{
+			Issuer: "auth", Subject: "42", Audience: common.Audience{"api", "admin"},
+			ExpiresAt: common.NewNumericDate(now.Add(time.Hour)), IssuedAt: common.NewNumericDate(now),
+		}, "admin"}
+		token, err := common.SignJWT(claims, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := common.ParseJWT[userClaims](token, v)
+		if err != nil || got.Subject != "42" || got.Role != "admin" || !got.Audience.Contains("admin") {
+			t.Errorf("%s: %+v %v", key.Algorithm, got, err)
+		}
+
+		// same kid and alg, another key
+		forged, err := common.SignJWT(claims, others[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := common.ParseJWT[userClaims](forged, v); !errors.Is(err, common.ErrSignature) {
+			t.Errorf("%s: forged token %v", key.Algorithm, err)
+		}
+	}
+}
+
+func TestJWTClaims(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	key := common.NewHMACKey("", []byte("secret"))
+	v := &common.JWTVerifier{Keys: []*common.JWTKey{key}, Issuer: "auth", Audience: "api", Leeway: time.Minute,
+		Now: func() time.Time { return now }}
+	valid := common.RegisteredClaims{Issuer: "auth", Audience: common.Audience{"api"}}
+
+	for _, c := range []struct {
+		name  string
+		edit  func(c *common.RegisteredClaims)
+		err   error
+		valid bool
+	}{
+		{"valid", func(c *common.RegisteredClaims) {}, nil, true},
+		{"expired in the leeway", func(c *common.RegisteredClaims) { c.ExpiresAt = common.NewNumericDate(now.Add(-30 * time.Second)) }, nil, true},
+		{"expired", func(c *common.RegisteredClaims) { c.ExpiresAt = common.NewNumericDate(now.Add(-time.Minute)) }, common.ErrJWTExpired, false},
+		{"nbf in the leeway", func(c *common.RegisteredClaims) { c.NotBefore = common.NewNumericDate(now.Add(time.Minute)) }, nil, true},
+		{"nbf", func(c *common.RegisteredClaims) { c.NotBefore = common.NewNumericDate(now.Add(2 * time.Minute)) }, common.ErrJWTNotValidYet, false},
+		{"iat", func(c *common.RegisteredClaims) { c.IssuedAt = common.NewNumericDate(now.Add(time.Hour)) }, common.ErrJWTNotValidYet, false},
+		{"iss", func(c *common.RegisteredClaims) { c.Issuer = "other" }, common.ErrJWTClaims, false},
+		{"aud", func(c *common.RegisteredClaims) { c.Audience = common.Audience{"web"} }, common.ErrJWTClaims, false},
+	} {
+		claims := valid
+		c.edit(&claims)
+		token, _ := common.SignJWT(claims, key)
+		_, err := common.ParseJWT[common.RegisteredClaims](token, v)
+		if c.valid && err != nil || !c.valid && !errors.Is(err, c.err) {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+
+	token, _ := common.SignJWT(valid, key)
+	v.RequireExp = true
+	if _, err := common.ParseJWT[common.RegisteredClaims](token, v); !errors.Is(err, common.ErrJWTClaims) {
+		t.Errorf("missing exp: %v", err)
+	}
+}
+
+func TestJWTMalformed(t *testing.T) {
+	key := common.NewHMACKey("", []byte("secret"))
+	v := &common.JWTVerifier{Keys: []*common.JWTKey{key}}
+	token, _ := common.SignJWT(common.RegisteredClaims{Subject: "42"}, key)
+
+	for _, c := range []struct {
+		token string
+		err   error
+	}{
+		{"", common.ErrJWTMalformed},
+		{"a.b", common.ErrJWTMalformed},
+		{"!!.e30.", common.ErrJWTMalformed},
+		// alg none
+		{"eyJhbGciOiJub25lIn0.eyJzdWIiOiI0MiJ9.", common.ErrJWTKey},
+		{token[:len(token)-2] + "AA", common.ErrSignature},
+	} {
+		if _, err := common.ParseJWT[common.RegisteredClaims](c.token, v); !errors.Is(err, c.err) {
+			t.Errorf("%q: %v, expected %v", c.token, err, c.err)
+		}
+	}
+	null, _ := common.SignJWT(nil, key)
+	if _, err := common.ParseJWT[*userClaims](null, v); !errors.Is(err, common.ErrJWTMalformed) {
+		t.Errorf("null claims: %v", err)
+	}
+}
+
+func TestJWTVector(t *testing.T) {
+	// RFC 7515 A.1
+	jwks := `{"keys":[{"kty":"oct","k":"AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow"}]}`
+	token := "eyJ0eXAiOiJKV1QiLA0KICJhbGciOiJIUzI1NiJ9" +
+		".eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ" +
+		".dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	keys, err := common.ParseJWKS([]byte(jwks))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := &common.JWTVerifier{Keys: keys, Issuer: "joe", Now: func() time.Time { return time.Unix(1300819000, 0) }}
+	if claims, err := common.ParseJWT[common.RegisteredClaims](token, v); err != nil || claims.ExpiresAt != 1300819380 {
+		t.Errorf("%+v %v", claims, err)
+	}
+	v.Now = nil
+	if _, err := common.ParseJWT[common.RegisteredClaims](token, v); !errors.Is(err, common.ErrJWTExpired) {
+		t.Errorf("expired: %v", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	signing, public := jwtKeys(t)
+	if _, err := common.JWKSHandler(signing[0]); err == nil {
+		t.Error("hmac secret published")
+	}
+	handler, err := common.JWKSHandler(signing[1:]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	keys, err := common.FetchJWKS(context.Background(), server.URL)
+	if err != nil || len(keys) != len(public)-1 {
+		t.Fatalf("%d keys %v", len(keys), err)
+	}
+	v := &common.JWTVerifier{Keys: keys}
+	for _, key := range signing[1:] {
+		token, _ := common.SignJWT(common.RegisteredClaims{Subject: key.ID}, key)
+		if claims, err := common.ParseJWT[common.RegisteredClaims](token, v); err != nil || claims.Subject != key.ID {
+			t.Errorf("%s: %v", key.Algorithm, err)
+		}
+	}
+
+	// keys of other uses and types are skipped, an alg of another key type is an error
+	fileName := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(fileName, []byte(`{"keys":[
+		{"kty":"OKP","crv":"X25519","x":"AAAA"},
+		{"kty":"RSA","use":"enc","n":"AQAB","e":"AQAB"},
+		{"kty":"OKP","crv":"Ed25519","kid":"ed","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}]}`), 0644)
+	if keys, err := common.LoadJWKS(fileName); err != nil || len(keys) != 1 || keys[0].ID != "ed" || keys[0].Algorithm != common.EdDSA {
+		t.Errorf("%v %v", keys, err)
+	}
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if _, err := common.NewJWK(&common.JWTKey{Algorithm: common.ES256, Key: p384}); err == nil {
+		t.Error("P-384 key published as P-256")
+	}
+	if _, err := common.ParseJWKS([]byte(`{"keys":[{"kty":"OKP","crv":"Ed25519","alg":"RS256","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}]}`)); err == nil {
+		t.Error("alg of another key type accepted")
+	}
+}
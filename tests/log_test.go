@@ -1,7 +1,13 @@
 package tests
 
 import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kbrownehs18/gotools/log"
 )
@@ -41,3 +47,98 @@ func BenchmarkLog(b *testing.B) {
 		logger.Error("Test info message error benchmark")
 	}
 }
+
+func newBenchLogger(b testing.TB) *log.Logger {
+	fileHandler, err := log.NewFileHandler("./logs", "bench.log", "daily", 20)
+	if err != nil {
+		b.Fatal(err)
+	}
+	logger, err := log.NewLogger("Bench", "file", "error", fileHandler)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return logger
+}
+
+func TestLogDisabledNoAlloc(t *testing.T) {
+	logger := newBenchLogger(t)
+	user, elapsed := "bob", 120*time.Millisecond
+	allocs := testing.AllocsPerRun(100, func() {
+		logger.Debugw("request done", log.String("user", user), log.Int("status", 200),
+			log.Duration("elapsed", elapsed), log.Err(nil))
+	})
+	if allocs != 0 {
+		t.Errorf("disabled Debugw allocates %v times", allocs)
+	}
+	if logger.Enabled(log.DEBUG) || !logger.Enabled(log.FATAL) {
+		t.Error("Enabled error")
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logger, read := newFileLogger(t, dir, log.TEXT)
+
+	logger.Errorw("request done", log.String("user", "bob smith"), log.Int("status", 500),
+		log.Bool("retry", true), log.Err(errors.New("timeout")))
+	r, ok := log.ParseRecord(strings.SplitN(read(), "\n", 2)[0])
+	if !ok || r.Fields["user"] != "bob smith" || r.Fields["status"] != "500" || r.Fields["retry"] != "true" {
+		t.Errorf("text fields error: %+v", r)
+	}
+
+	logger.SetFormat(log.JSON)
+	logger.Errorw("request done", log.Float64("ratio", 0.5), log.Any("tags", []string{"a"}),
+		log.Err(errors.New("timeout")))
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(read()), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["ratio"] != 0.5 || entry["tags"].([]interface{})[0] != "a" ||
+		entry["error"].(map[string]interface{})["message"] != "timeout" {
+		t.Errorf("json fields error: %v", entry)
+	}
+}
+
+// BenchmarkLogDisabled disabled level with the fmt style api
+func BenchmarkLogDisabled(b *testing.B) {
+	logger := newBenchLogger(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debug("Test debug message benchmark ", i)
+	}
+}
+
+// BenchmarkLogDisabledf disabled level with the printf style api
+func BenchmarkLogDisabledf(b *testing.B) {
+	logger := newBenchLogger(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debugf("Test debug message benchmark %d", i)
+	}
+}
+
+// BenchmarkLogDisabledFields disabled level with typed fields, no allocation
+func BenchmarkLogDisabledFields(b *testing.B) {
+	logger := newBenchLogger(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debugw("Test debug message benchmark", log.Int("i", i), log.String("user", "bob"))
+	}
+}
+
+// BenchmarkLogFields enabled level with typed fields
+func BenchmarkLogFields(b *testing.B) {
+	logger := newBenchLogger(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Errorw("Test error message benchmark", log.Int("i", i), log.String("user", "bob"))
+	}
+}
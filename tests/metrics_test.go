@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/log"
+)
+
+func TestMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileHandler, err := log.NewFileHandler(dir, "metrics.log", "size", 1, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileHandler.Close()
+	// logger counters live as long as the process, a new name per run
+	name := "Metrics-" + filepath.Base(dir)
+	logger, err := log.NewLogger(name, "file", "info", fileHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Debug("not counted")
+	logger.Info("info message")
+	logger.Error("error message")
+	logger.Error("error message, rotate")
+
+	m := log.ReadMetrics()
+	if m.Entries[name][log.ERROR] != 2 || m.Entries[name][log.INFO] != 1 || m.Entries[name][log.DEBUG] != 0 {
+		t.Errorf("entries error: %v", m.Entries[name])
+	}
+	h := fileHandler.Metrics()
+	if h.Writes != 3 || h.BytesWritten == 0 || h.Rotations == 0 || !h.Healthy {
+		t.Errorf("handler metrics error: %+v", h)
+	}
+
+	// a directory in the way of the next backup makes rotation fail
+	if err := os.Remove(filepath.Join(dir, "metrics.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "metrics.log.1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "metrics.log.1", "x"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	logger.Error("error message, rotation fails")
+	logger.Error("error message, rotation fails again")
+	h = fileHandler.Metrics()
+	if h.RotationErrors == 0 || h.Healthy || h.LastError == "" {
+		t.Errorf("rotation error not counted: %+v", h)
+	}
+
+	w := httptest.NewRecorder()
+	log.MetricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, s := range []string{
+		`gotools_log_entries_total{logger="` + name + `",level="ERROR"} 4`,
+		"# TYPE gotools_log_handler_rotation_errors_total counter",
+		`gotools_log_handler_up{file="` + filepath.Join(dir, "metrics.log") + `"} 0`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("metrics output has no %s:\n%s", s, body)
+		}
+	}
+}
+
+func TestHandlerMetricsClose(t *testing.T) {
+	fs := log.NewMemFS(nil)
+	fileHandler, err := log.NewFileHandlerFS(fs, nil, "logs", "closed.log", "size", 1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileHandler.Write([]byte("first line\n"))
+	fileHandler.Write([]byte("second line\n"))
+	if h := log.ReadMetrics().Handlers["logs/closed.log"]; h.Writes != 2 || h.Rotations != 1 {
+		t.Errorf("handler metrics error: %+v", h)
+	}
+	fileHandler.Close()
+	if _, ok := log.ReadMetrics().Handlers["logs/closed.log"]; ok {
+		t.Error("closed handler still exported")
+	}
+
+	// a new handler of the same file starts from zero
+	fileHandler, err = log.NewFileHandlerFS(fs, nil, "logs", "closed.log", "size", 1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileHandler.Close()
+	if h := fileHandler.Metrics(); h.Writes != 0 || h.Rotations != 0 {
+		t.Errorf("counters of the closed handler inherited: %+v", h)
+	}
+}
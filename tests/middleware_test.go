@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kbrownehs18/gotools/common"
+	"github.com/kbrownehs18/gotools/log"
+)
+
+func TestMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("User-Agent") + "|" + r.Header.Get("X-Trace")))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	var order []string
+	mark := func(name string) common.Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return common.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	var timed time.Duration
+	client := common.NewClient(mark("a"), mark("b"), common.UserAgent("app/1.0"), common.BearerAuth("t0k"),
+		common.Header("X-Trace", "1"), common.Timing(func(req *http.Request, res *http.Response, err error, d time.Duration) {
+			timed = d
+		}))
+	res, err := common.NewRequest(server.URL).Client(client).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.String() != "Bearer t0k|app/1.0|1" || strings.Join(order, "") != "ab" || timed <= 0 {
+		t.Errorf("middleware error: %s %v %s", res, order, timed)
+	}
+
+	// headers of the request win
+	res, err = common.NewRequest(server.URL).Header("Authorization", "own").
+		Use(common.BasicAuth("user", "pass"), common.UserAgent("app/1.0")).Do(ctx)
+	if err != nil || res.String() != "own|app/1.0|" {
+		t.Errorf("header override error: %s %v", res, err)
+	}
+	res, err = common.NewRequest(server.URL).Use(common.BasicAuth("user", "pass")).Do(ctx)
+	if err != nil || !strings.HasPrefix(res.String(), "Basic dXNlcjpwYXNz|") {
+		t.Errorf("basic auth error: %s %v", res, err)
+	}
+
+	logger, read := newFileLogger(t, t.TempDir(), log.TEXT)
+	common.NewRequest(server.URL + "/ok?secret=1").Use(log.HTTPLogging(logger)).Do(ctx)
+	common.NewRequest(server.URL + "/fail").Method(common.DELETE).Use(log.HTTPLogging(logger)).Do(ctx)
+	common.NewRequest("http://127.0.0.1:1/down").Use(log.HTTPLogging(logger)).Do(ctx)
+	lines := strings.Split(read(), "\n")
+	if !strings.Contains(lines[0], "[INFO]") || !strings.Contains(lines[0], "http request method=GET url="+server.URL+"/ok status=200 duration=") ||
+		strings.Contains(lines[0], "secret") {
+		t.Errorf("log error: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "[WARNING]") || !strings.Contains(lines[1], "method=DELETE url="+server.URL+"/fail status=500") {
+		t.Errorf("log error: %s", lines[1])
+	}
+	if !strings.Contains(lines[2], "[ERROR]") || !strings.Contains(lines[2], "http request failed method=GET url=http://127.0.0.1:1/down") {
+		t.Errorf("log error: %s", lines[2])
+	}
+}
@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+// uploadHandler reply the request content length and the size and checksum of each part
+func uploadHandler(w http.ResponseWriter, r *http.Request) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	parts := map[string]string{"length": jsonInt(r.ContentLength)}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var n int64
+		var b []byte
+		if p.FileName() == "" {
+			b, _ = ioutil.ReadAll(p)
+			n = int64(len(b))
+		} else {
+			n, _ = io.Copy(ioutil.Discard, p)
+			b = []byte(p.FileName() + " " + p.Header.Get("Content-Type"))
+		}
+		parts[p.FormName()] = string(b) + " " + jsonInt(n)
+	}
+	json.NewEncoder(w).Encode(parts)
+}
+
+func jsonInt(n int64) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
+
+func TestMultipart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(uploadHandler))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "a.txt")
+	if err := ioutil.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent, total int64
+	m := common.NewMultipart().Field("name", "report").FilePath("file", file).
+		File(common.FilePart{Field: "data", FileName: `b "2".csv`, ContentType: "text/csv",
+			Open: func() (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader("1,2\n")), nil }, Size: 4}).
+		Progress(func(s, t int64) { sent, total = s, t })
+	res, err := common.NewRequest(server.URL).Method(common.POST).Multipart(m).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts map[string]string
+	if err := res.JSON(&parts); err != nil {
+		t.Fatal(err, res.String())
+	}
+	if parts["length"] != jsonInt(m.Length()) || parts["name"] != "report 6" ||
+		parts["file"] != "a.txt application/octet-stream 5" || parts["data"] != `b "2".csv text/csv 4` {
+		t.Errorf("multipart error: %v", parts)
+	}
+	if sent != total || total != m.Length() {
+		t.Errorf("progress error: %d of %d", sent, total)
+	}
+
+	// unknown size is sent chunked, a reader only once
+	m = common.NewMultipart().File(common.FilePart{Field: "r", FileName: "r", Reader: strings.NewReader("abc")})
+	res, err = common.NewRequest(server.URL).Method(common.POST).Multipart(m).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.JSON(&parts); err != nil || parts["length"] != "-1" || parts["r"] != "r application/octet-stream 3" {
+		t.Errorf("unknown size error: %v %v", parts, err)
+	}
+	if _, err = m.Open(); err == nil {
+		t.Error("reader sent twice")
+	}
+
+	// an empty file has a known size
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	ioutil.WriteFile(empty, nil, 0644)
+	m = common.NewMultipart().FilePath("empty", empty)
+	res, err = common.NewRequest(server.URL).Method(common.POST).Multipart(m).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.JSON(&parts); err != nil || m.Length() < 0 || parts["length"] != jsonInt(m.Length()) ||
+		parts["empty"] != "empty.txt application/octet-stream 0" {
+		t.Errorf("empty file error: %v %v", parts, err)
+	}
+
+	m = common.NewMultipart().FilePath("file", filepath.Join(t.TempDir(), "missing"))
+	if _, err = common.NewRequest(server.URL).Method(common.POST).Multipart(m).Do(context.Background()); err == nil {
+		t.Error("missing file error")
+	}
+}
+
+// zeroReader n zero bytes
+type zeroReader struct {
+	n int64
+}
+
+func (r *zeroReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.n {
+		p = p[:r.n]
+	}
+	for i := range p {
+		p[i] = 0
+	}
+	r.n -= int64(len(p))
+	return len(p), nil
+}
+
+func (r *zeroReader) Close() error {
+	return nil
+}
+
+func TestMultipartStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(uploadHandler))
+	defer server.Close()
+
+	const size = 256 << 20
+	m := common.NewMultipart().File(common.FilePart{Field: "big", FileName: "big.bin", Size: size,
+		Open: func() (io.ReadCloser, error) { return &zeroReader{n: size}, nil }})
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	res, err := common.NewRequest(server.URL).Method(common.PUT).Multipart(m).Do(context.Background())
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(res.Body, []byte(`"big.bin application/octet-stream 268435456"`)) {
+		t.Errorf("streaming error: %s", res.Body)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > size/8 {
+		t.Errorf("body buffered, allocated %d bytes", alloc)
+	}
+}
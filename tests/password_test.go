@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+// cheap parameters to keep the tests fast
+var testPasswordHashers = map[string]*common.PasswordHasher{
+	"$argon2id$v=19$m=1024,t=1,p=1$": {Algorithm: common.Argon2id, Argon2Time: 1, Argon2Memory: 1024, Argon2Threads: 1},
+	"$scrypt$ln=10,r=8,p=1$":         {Algorithm: common.Scrypt, ScryptLogN: 10, ScryptR: 8, ScryptP: 1},
+	"$2a$04$":                        {Algorithm: common.Bcrypt, BcryptCost: 4},
+}
+
+func TestPassword(t *testing.T) {
+	for prefix, h := range testPasswordHashers {
+		hash, err := h.Hash("hunter2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(hash, prefix) {
+			t.Errorf("hash %s, expected prefix %s", hash, prefix)
+		}
+		if hash2, _ := h.Hash("hunter2"); hash2 == hash {
+			t.Errorf("%s: salt reused", prefix)
+		}
+
+		if err := common.VerifyPassword("hunter2", hash); err != nil {
+			t.Errorf("%s: verify error %v", prefix, err)
+		}
+		if err := common.VerifyPassword("hunter3", hash); !errors.Is(err, common.ErrPassword) {
+			t.Errorf("%s: wrong password %v", prefix, err)
+		}
+		if h.NeedsRehash(hash) {
+			t.Errorf("%s: rehash of its own hash", prefix)
+		}
+		if !common.NeedsRehash(hash) {
+			t.Errorf("%s: no rehash with the default parameters", prefix)
+		}
+	}
+}
+
+func TestPasswordVectors(t *testing.T) {
+	// hashlib.scrypt of python
+	hash := "$scrypt$ln=10,r=8,p=1$c2FsdHlzYWx0eXNhbHR5IQ$I8zi9hizCioTKzFT/+rEhiUjjvg7hSkT9+Il991mwyE"
+	if err := common.VerifyPassword("hunter2", hash); err != nil {
+		t.Errorf("scrypt vector: %v", err)
+	}
+
+	for _, hash := range []string{common.Md5Sum("hunter2"), strings.ToUpper(common.Md5Sum("hunter2")), common.SHA256("hunter2")} {
+		if err := common.VerifyPassword("hunter2", hash); err != nil {
+			t.Errorf("legacy %s: %v", hash, err)
+		}
+		if err := common.VerifyPassword("hunter3", hash); !errors.Is(err, common.ErrPassword) {
+			t.Errorf("legacy %s: wrong password %v", hash, err)
+		}
+		if !common.NeedsRehash(hash) {
+			t.Errorf("legacy %s: no rehash", hash)
+		}
+	}
+
+	hash, err := common.HashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$") || common.NeedsRehash(hash) {
+		t.Errorf("default hash %s", hash)
+	}
+}
+
+func TestPasswordInvalid(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plain",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$c2FsdHlzYWx0eXNhbHR5IQ",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$c2FsdHlzYWx0eXNhbHR5IQ",
+		"$argon2id$v=19$m=1024,t=1$c2FsdA$c2FsdHlzYWx0eXNhbHR5IQ",
+		"$scrypt$ln=10,r=8,p=1$c2FsdA$!!",
+		"$md5$abc",
+		// parameters of a hostile database row
+		"$argon2id$v=19$m=1024,t=4294967295,p=1$c2FsdA$c2FsdHlzYWx0eXNhbHR5IQ",
+		"$argon2id$v=19$m=1048576,t=1,p=255$c2FsdA$c2FsdHlzYWx0eXNhbHR5IQ",
+		"$scrypt$ln=30,r=8,p=1$c2FsdA$c2FsdHlzYWx0eXNhbHR5IQ",
+		"$scrypt$ln=16,r=1024,p=1$c2FsdA$c2FsdHlzYWx0eXNhbHR5IQ",
+		"$scrypt$ln=10,r=8,p=100000$c2FsdA$c2FsdHlzYWx0eXNhbHR5IQ",
+	} {
+		if err := common.VerifyPassword("hunter2", hash); err == nil || errors.Is(err, common.ErrPassword) {
+			t.Errorf("hash %q: %v", hash, err)
+		}
+		if !common.NeedsRehash(hash) {
+			t.Errorf("hash %q: no rehash", hash)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/log"
+)
+
+// TestConcurrentLevels run with -race, entries logged at different levels at once
+// must keep their own level labels
+func TestConcurrentLevels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileHandler, err := log.NewFileHandler(dir, "race.log", "none", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileHandler.Close()
+	logger, err := log.NewLogger("Race", "file", "trace", fileHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writers := map[log.Level]func(format string, v ...interface{}){
+		log.TRACE:   logger.Tracef,
+		log.DEBUG:   logger.Debugf,
+		log.INFO:    logger.Infof,
+		log.WARNING: logger.Warningf,
+		log.ERROR:   logger.Errorf,
+	}
+	const goroutines, entries = 8, 200
+	var wg sync.WaitGroup
+	for level, write := range writers {
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func(level log.Level, write func(format string, v ...interface{})) {
+				defer wg.Done()
+				for i := 0; i < entries; i++ {
+					write("level=%s i=%d", level, i)
+				}
+			}(level, write)
+		}
+	}
+	wg.Wait()
+
+	fd, err := os.Open(filepath.Join(dir, "race.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	var n int
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		r, ok := log.ParseRecord(scanner.Text())
+		if !ok {
+			t.Fatalf("broken line: %s", scanner.Text())
+		}
+		if r.Level.String() != r.Fields["level"] || !strings.HasPrefix(r.Raw, "[Race]") {
+			t.Fatalf("crossed level label: %s", r.Raw)
+		}
+		n++
+	}
+	if n != len(writers)*goroutines*entries {
+		t.Errorf("expect %d entries, got %d", len(writers)*goroutines*entries, n)
+	}
+}
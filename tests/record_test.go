@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/log"
+)
+
+func TestParseRecord(t *testing.T) {
+	r, ok := log.ParseRecord(`[Test][ERROR]2026/10/19 16:14:26 log_test.go:18: save failed user=bob msg="a b"`)
+	if !ok {
+		t.Fatal("text record not parsed")
+	}
+	if r.Logger != "Test" || r.Level != log.ERROR || r.Caller != "log_test.go:18" {
+		t.Errorf("text record error: %+v", r)
+	}
+	if r.Time.Format("2006-01-02 15:04:05") != "2026-10-19 16:14:26" {
+		t.Errorf("text record time error: %s", r.Time)
+	}
+	if r.Fields["user"] != "bob" || r.Fields["msg"] != "a b" {
+		t.Errorf("text record fields error: %v", r.Fields)
+	}
+
+	if _, ok := log.ParseRecord("    at main.go:12"); ok {
+		t.Error("continuation line parsed as record")
+	}
+	r.Append("    at main.go:12")
+	if r.Message != "save failed user=bob msg=\"a b\"\n    at main.go:12" {
+		t.Errorf("append error: %q", r.Message)
+	}
+
+	r, ok = log.ParseRecord(`{"time":"2026-10-19T16:14:26+08:00","level":"WARNING","logger":"api","msg":"slow","ms":120}`)
+	if !ok {
+		t.Fatal("json record not parsed")
+	}
+	if r.Logger != "api" || r.Level != log.WARNING || r.Message != "slow" || r.Fields["ms"] != "120" {
+		t.Errorf("json record error: %+v", r)
+	}
+}
+
+func TestBackupFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	names := []string{"error.log", "error.log.1", "error.log.2.gz", "error.log.2026-10-16",
+		"error.log.2026-10-15.gz", "error.log.bak", "access.log"}
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := log.BackupFiles(filepath.Join(dir, "error.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"error.log.2026-10-15.gz", "error.log.2026-10-16", "error.log.2.gz",
+		"error.log.1", "error.log"}
+	if len(files) != len(expected) {
+		t.Fatalf("files error: %v", files)
+	}
+	for i, name := range expected {
+		if files[i] != filepath.Join(dir, name) {
+			t.Errorf("files[%d] = %s, expect %s", i, files[i], name)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+// echoHandler echo method, query, headers and body as json
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	if d, err := time.ParseDuration(r.URL.Query().Get("sleep")); err == nil {
+		select {
+		case <-time.After(d):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Echo", "1")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"method":       r.Method,
+		"query":        r.URL.Query(),
+		"content_type": r.Header.Get("Content-Type"),
+		"token":        r.Header.Get("X-Token"),
+		"body":         string(body),
+	})
+}
+
+type echo struct {
+	Method      string              `json:"method"`
+	Query       map[string][]string `json:"query"`
+	ContentType string              `json:"content_type"`
+	Token       string              `json:"token"`
+	Body        string              `json:"body"`
+}
+
+func TestRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+	ctx := context.Background()
+
+	res, err := common.NewRequest(server.URL+"/items?a=1").Method(common.PUT).Query("id", "7").
+		Header("X-Token", "t0k").JSON(map[string]int{"n": 1}).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e echo
+	if err := res.JSON(&e); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || res.Header.Get("X-Echo") != "1" {
+		t.Errorf("response error: %+v", res)
+	}
+	if e.Method != "PUT" || e.Query["a"][0] != "1" || e.Query["id"][0] != "7" || e.Token != "t0k" ||
+		e.Body != `{"n":1}` || e.ContentType != "application/json; charset=utf-8" {
+		t.Errorf("echo error: %+v", e)
+	}
+
+	for _, m := range []common.Method{common.GET, common.DELETE, common.PATCH, common.OPTIONS} {
+		res, err := common.NewRequest(server.URL).Method(m).Form("k", "v").Do(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := res.JSON(&e); err != nil || e.Method != m.String() {
+			t.Errorf("method %s error: %+v %v", m, e, err)
+		}
+	}
+
+	_, err = common.NewRequest(server.URL).Query("sleep", "1s").Timeout(50 * time.Millisecond).Do(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("timeout error: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err = common.NewRequest(server.URL).Do(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancel error: %v", err)
+	}
+}
+
+// flakyServer fail the first failures requests of each path with status
+func flakyServer(failures int32, status int, header http.Header) (*httptest.Server, *int32) {
+	var n int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&n, 1) <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write(body)
+	})), &n
+}
+
+func TestRequestRetry(t *testing.T) {
+	ctx := context.Background()
+	policy := common.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	server, n := flakyServer(2, http.StatusServiceUnavailable, nil)
+	res, err := common.NewRequest(server.URL).Method(common.PUT).JSON("replayed").Retry(policy).Do(ctx)
+	server.Close()
+	if err != nil || res.StatusCode != http.StatusOK || res.String() != `"replayed"` || *n != 3 {
+		t.Errorf("retry error: %v %+v attempts %d", err, res, *n)
+	}
+
+	// POST is not idempotent
+	server, n = flakyServer(2, http.StatusServiceUnavailable, nil)
+	res, err = common.NewRequest(server.URL).Method(common.POST).Form("k", "v").Retry(policy).Do(ctx)
+	server.Close()
+	if err != nil || res.StatusCode != http.StatusServiceUnavailable || *n != 1 {
+		t.Errorf("post retry error: %v %+v attempts %d", err, res, *n)
+	}
+
+	server, n = flakyServer(2, http.StatusServiceUnavailable, nil)
+	res, err = common.NewRequest(server.URL).Method(common.POST).Header("Idempotency-Key", "1").
+		Form("k", "v").Retry(policy).Do(ctx)
+	server.Close()
+	if err != nil || res.String() != "k=v" || *n != 3 {
+		t.Errorf("idempotency key retry error: %v %+v attempts %d", err, res, *n)
+	}
+
+	// Retry-After longer than MaxBackoff returns the response
+	server, n = flakyServer(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"60"}})
+	res, err = common.NewRequest(server.URL).Retry(policy).Do(ctx)
+	server.Close()
+	if err != nil || res.StatusCode != http.StatusTooManyRequests || *n != 1 {
+		t.Errorf("retry after error: %v %+v attempts %d", err, res, *n)
+	}
+
+	server, n = flakyServer(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"0"}})
+	res, err = common.NewRequest(server.URL).Retry(policy).Do(ctx)
+	server.Close()
+	if err != nil || res.StatusCode != http.StatusOK || *n != 2 {
+		t.Errorf("retry after error: %v %+v attempts %d", err, res, *n)
+	}
+
+	// cancelled while waiting
+	server, _ = flakyServer(5, http.StatusBadGateway, nil)
+	policy.MinBackoff, policy.MaxBackoff = time.Second, time.Second
+	start := time.Now()
+	_, err = common.NewRequest(server.URL).Retry(policy).Timeout(50 * time.Millisecond).Do(ctx)
+	server.Close()
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("cancel retry error: %v after %s", err, time.Since(start))
+	}
+}
+
+func TestHttpRequestRetry(t *testing.T) {
+	dir := t.TempDir()
+	file := dir + "/upload.txt"
+	if err := ioutil.WriteFile(file, []byte("file content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server, n := flakyServer(1, http.StatusBadGateway, nil)
+	defer server.Close()
+	policy := common.RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond, AllMethods: true}
+	res, err := common.HTTPRequest(server.URL, common.POST, map[string]string{"file": "\x00@" + file},
+		map[string]string{}, true, http.DefaultClient, policy)
+	if err != nil || *n != 2 || !strings.Contains(res, "file content") {
+		t.Errorf("upload retry error: %v %q attempts %d", err, res, *n)
+	}
+}
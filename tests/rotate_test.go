@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kbrownehs18/gotools/log"
+)
+
+func TestDailyRotate(t *testing.T) {
+	now := time.Date(2026, 10, 1, 10, 0, 0, 0, time.Local)
+	clock := log.ClockFunc(func() time.Time { return now })
+	fs := log.NewMemFS(clock)
+
+	fileHandler, err := log.NewFileHandlerFS(fs, clock, "logs", "app.log", "daily", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileHandler.Close()
+	logger, err := log.NewLogger("Rotate", "file", "info", fileHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for day := 0; day < 6; day++ {
+		logger.Infof("day %d", day)
+		logger.Infof("day %d again", day)
+		now = now.Add(24 * time.Hour)
+	}
+
+	// the current file and backupCount-1 daily backups
+	expected := []string{"logs/app.log", "logs/app.log.2026-10-04", "logs/app.log.2026-10-05"}
+	if files := fs.Files(); !reflect.DeepEqual(files, expected) {
+		t.Errorf("files %v, expect %v", files, expected)
+	}
+	b, err := fs.ReadFile("logs/app.log.2026-10-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); strings.Count(s, "\n") != 2 || !strings.Contains(s, "day 4 again") {
+		t.Errorf("backup content error: %s", s)
+	}
+	if m := fileHandler.Metrics(); m.Rotations != 5 || m.RotationErrors != 0 {
+		t.Errorf("metrics error: %+v", m)
+	}
+}
+
+func TestSizeRotate(t *testing.T) {
+	fs := log.NewMemFS(nil)
+	fileHandler, err := log.NewFileHandlerFS(fs, nil, "logs", "app.log", "size", 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"first line\n", "second line\n", "third line\n", "fourth line\n"} {
+		if _, err := fileHandler.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := map[string]string{
+		"logs/app.log":   "fourth line\n",
+		"logs/app.log.1": "third line\n",
+		"logs/app.log.2": "second line\n",
+	}
+	if files := fs.Files(); len(files) != len(expected) {
+		t.Errorf("files error: %v", files)
+	}
+	for name, content := range expected {
+		b, err := fs.ReadFile(name)
+		if err != nil || string(b) != content {
+			t.Errorf("%s content %q, expect %q, %v", name, b, content, err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+// rsaKeys PEM keys of a new key pair: PKIX public, PKCS #8 private, PKCS #1 public and private
+func rsaKeys(t testing.TB, bits int) (pub, priv, pub1, priv1 []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkix, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)}),
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func TestRSAEncrypt(t *testing.T) {
+	text := bytes.Repeat([]byte("0123456789"), 100)
+	sizes := []int{1024, 2048}
+	if !testing.Short() {
+		sizes = append(sizes, 4096)
+	}
+	for _, bits := range sizes {
+		pub, priv, pub1, priv1 := rsaKeys(t, bits)
+		for _, padding := range []common.RSAPadding{common.PKCS1v15, common.OAEPSHA1, common.OAEPSHA256} {
+			b, err := common.RSAEncrypt(text, pub, padding)
+			if err != nil {
+				t.Fatalf("%d bits, padding %d: %v", bits, padding, err)
+			}
+			if len(b)%(bits/8) != 0 {
+				t.Errorf("%d bits, padding %d: %d bytes encrypted", bits, padding, len(b))
+			}
+			plain, err := common.RSADecrypt(b, priv, padding)
+			if err != nil || !bytes.Equal(plain, text) {
+				t.Errorf("%d bits, padding %d: decrypt error %v", bits, padding, err)
+			}
+
+			b, err = common.RSAEncrypt(text, pub1, padding, common.PKCS1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plain, err = common.RSADecrypt(b, priv1, padding, common.PKCS1); err != nil || !bytes.Equal(plain, text) {
+				t.Errorf("%d bits, padding %d: PKCS1 decrypt error %v", bits, padding, err)
+			}
+		}
+
+		b, err := common.RSAEncode(text, pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain, err := common.RSADecode(b, priv); err != nil || !bytes.Equal(plain, text) {
+			t.Errorf("%d bits: RSADecode error %v", bits, err)
+		}
+		if _, err := common.RSADecrypt(b, priv, common.OAEPSHA256); err == nil {
+			t.Errorf("%d bits: decrypted with the wrong padding", bits)
+		}
+		if _, err := common.RSADecrypt(b[1:], priv, common.PKCS1v15); err == nil {
+			t.Errorf("%d bits: decrypted a partial block", bits)
+		}
+	}
+}
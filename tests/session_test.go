@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+func TestSession(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("password") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "42", Path: "/admin", HttpOnly: true})
+	})
+	mux.HandleFunc("/admin/orders", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("sid")
+		if err != nil || c.Value != "42" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(r.URL.RawQuery + " " + r.Header.Get("User-Agent")))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	ctx := context.Background()
+
+	s, err := common.NewSession(server.URL + "/admin/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Header("User-Agent", "ops/1.0")
+	if res, err := s.Get(ctx, "orders"); err != nil || res.StatusCode != http.StatusForbidden {
+		t.Fatalf("logged out error: %v %v", res, err)
+	}
+	if res, err := s.PostForm(ctx, "login", map[string]string{"password": "secret"}); err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("login error: %v %v", res, err)
+	}
+	res, err := s.Get(ctx, "orders?page=2")
+	if err != nil || res.StatusCode != http.StatusOK || res.String() != "page=2 ops/1.0" {
+		t.Errorf("session error: %v %v", res, err)
+	}
+	// request headers win over session headers
+	res, err = s.Request(server.URL+"/admin/orders").Header("User-Agent", "other").Do(ctx)
+	if err != nil || res.String() != " other" {
+		t.Errorf("absolute url error: %v %v", res, err)
+	}
+
+	file := filepath.Join(t.TempDir(), "cookies.json")
+	if err := s.SaveCookies(file); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("cookie file mode: %v %v", info, err)
+	}
+	s2, _ := common.NewSession(server.URL + "/admin/")
+	if err := s2.LoadCookies(file); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := s2.Get(ctx, "orders"); err != nil || res.StatusCode != http.StatusOK {
+		t.Errorf("loaded cookies error: %v %v", res, err)
+	}
+	if err := s2.LoadCookies(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("missing cookie file error: %v", err)
+	}
+}
+
+func TestSessionRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	s, _ := common.NewSession(server.URL)
+	s.RateLimit(2, 100*time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := s.Get(context.Background(), "/"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a burst of 2, then 2 more at 50ms each
+	if d := time.Since(start); d < 90*time.Millisecond || d > time.Second {
+		t.Errorf("rate limit took %s", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	s.Get(ctx, "/")
+	if _, err := s.Get(ctx, "/"); err == nil {
+		t.Error("rate limit should wait past the deadline")
+	}
+
+	for _, c := range []struct {
+		n   int
+		per time.Duration
+	}{{0, time.Second}, {-1, time.Second}, {1, 0}} {
+		s, _ := common.NewSession(server.URL)
+		s.RateLimit(c.n, c.per)
+		if _, err := s.Get(context.Background(), "/"); err == nil {
+			t.Errorf("rate limit of %d per %s", c.n, c.per)
+		}
+	}
+}
+
+func TestCookieJar(t *testing.T) {
+	jar := common.NewCookieJar()
+	u, _ := url.Parse("https://www.example.com/a/b")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "secure", Value: "3", Path: "/", Secure: true},
+		{Name: "other", Value: "4", Domain: "other.com"},
+		{Name: "expired", Value: "5", MaxAge: -1},
+		{Name: "old", Value: "6", Expires: time.Now().Add(-time.Hour)},
+	})
+
+	names := func(raw string) []string {
+		u, _ := url.Parse(raw)
+		var names []string
+		for _, c := range jar.Cookies(u) {
+			names = append(names, c.Name+"="+c.Value)
+		}
+		return names
+	}
+	for raw, want := range map[string]string{
+		"https://www.example.com/a/c":  "[host=1 domain=2 secure=3]",
+		"https://www.example.com/ab":   "[domain=2 secure=3]",
+		"http://www.example.com/a/":    "[host=1 domain=2]",
+		"https://api.example.com/a/b":  "[domain=2]",
+		"https://www.other.com/":       "[]",
+		"https://www.example.com:8443": "[domain=2 secure=3]",
+	} {
+		if got := fmt.Sprint(names(raw)); got != want {
+			t.Errorf("cookies of %s: %s, want %s", raw, got, want)
+		}
+	}
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "host", Value: "", MaxAge: -1}})
+	if got := fmt.Sprint(names("https://www.example.com/a/c")); got != "[domain=2 secure=3]" {
+		t.Errorf("deleted cookie: %s", got)
+	}
+	if len(jar.All()) != 2 {
+		t.Errorf("all cookies: %v", jar.All())
+	}
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+func TestSigner(t *testing.T) {
+	// example of the wechat pay signature documentation
+	params := map[string]string{
+		"appid":       "wxd930ea5d5a258f4f",
+		"mch_id":      "10000100",
+		"device_info": "1000",
+		"body":        "test",
+		"nonce_str":   "ibuaiVcKdpRxkhJA",
+		"attach":      "",
+	}
+	signer := &common.Signer{Key: "192006250b4c09247ec02edce69f6a2d", Upper: true}
+	if s := signer.String(params); s != "appid=wxd930ea5d5a258f4f&body=test&device_info=1000&mch_id=10000100&nonce_str=ibuaiVcKdpRxkhJA" {
+		t.Errorf("string error: %s", s)
+	}
+	sign, err := signer.Sign(params)
+	if err != nil || sign != "9A0A8659F005D6984697E2CA0A9CF3B7" {
+		t.Errorf("md5 sign error: %s %v", sign, err)
+	}
+
+	params["sign"] = strings.ToLower(sign)
+	if err := signer.Verify(params); err != nil {
+		t.Errorf("verify error: %v", err)
+	}
+	params["body"] = "changed"
+	if err := signer.Verify(params); !errors.Is(err, common.ErrSignature) {
+		t.Errorf("verify changed error: %v", err)
+	}
+	params["body"] = "test"
+
+	signer.Algorithm = common.SignHMACSHA256
+	if sign, _ = signer.Sign(params); sign != strings.ToUpper(common.HMacSHA256(signer.String(params), signer.Key)) {
+		t.Errorf("hmac sign error: %s", sign)
+	}
+
+	signer = &common.Signer{Algorithm: common.SignMD5, Key: "k", KeyPlacement: common.KeyWrap,
+		SignField: "signature", Exclude: []string{"sign_type"}, KeepEmpty: true}
+	params = map[string]string{"b": "2", "a": "", "sign_type": "MD5", "signature": "x"}
+	if sign, _ = signer.Sign(params); sign != common.Md5Sum("ka=&b=2k") {
+		t.Errorf("key wrap error: %s", sign)
+	}
+	signer.KeyPlacement = common.KeySuffix
+	if sign, _ = signer.Sign(params); sign != common.Md5Sum("a=&b=2k") {
+		t.Errorf("key suffix error: %s", sign)
+	}
+
+	signer = &common.Signer{Algorithm: common.SignMD5, Key: "k", KeyName: "secret", Joiner: ";", Separator: ":"}
+	if sign, _ = signer.Sign(params); sign != common.Md5Sum("b:2;sign_type:MD5;signature:x;secret:k") {
+		t.Errorf("key param separator error: %s", sign)
+	}
+}
+
+func TestSignerRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	pkix, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	signer := &common.Signer{
+		Algorithm: common.SignRSASHA256,
+		Key:       string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})),
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})),
+		Exclude:   []string{"sign_type"},
+	}
+
+	params := map[string]string{"out_trade_no": "20150320010101001", "total_amount": "88.88", "sign_type": "RSA2"}
+	sign, err := signer.Sign(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signed callback
+	form := url.Values{}
+	for k, v := range params {
+		form.Set(k, v)
+	}
+	form.Set("sign", sign)
+	var verified error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verified = signer.VerifyRequest(r)
+	}))
+	defer server.Close()
+	if _, err := http.PostForm(server.URL, form); err != nil || verified != nil {
+		t.Errorf("verify request error: %v %v", err, verified)
+	}
+
+	form.Set("total_amount", "0.01")
+	if _, err := http.PostForm(server.URL, form); err != nil || !errors.Is(verified, common.ErrSignature) {
+		t.Errorf("verify changed request error: %v %v", err, verified)
+	}
+}
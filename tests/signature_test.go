@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+// pemKeys PEM PKCS #8 private and PKIX public key
+func pemKeys(t *testing.T, priv interface{}, pub interface{}) ([]byte, []byte) {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkix, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})
+}
+
+func TestSignature(t *testing.T) {
+	data := []byte("out_trade_no=20150320010101001&total_amount=88.88")
+
+	rsaPub, rsaPriv, rsaPub1, rsaPriv1 := rsaKeys(t, 2048)
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p256Priv, p256Pub := pemKeys(t, p256, &p256.PublicKey)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p384Priv, p384Pub := pemKeys(t, p384, &p384.PublicKey)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edPriv, edPubPEM := pemKeys(t, edKey, edPub)
+
+	for _, c := range []struct {
+		algorithm common.SignatureAlgorithm
+		priv, pub []byte
+	}{
+		{common.RSASHA256, rsaPriv, rsaPub},
+		{common.RSASHA1, rsaPriv, rsaPub},
+		{common.RSAPSSSHA256, rsaPriv, rsaPub},
+		{common.ECDSASHA256, p256Priv, p256Pub},
+		{common.ECDSASHA384, p384Priv, p384Pub},
+		{common.Ed25519, edPriv, edPubPEM},
+	} {
+		sig, err := common.SignBase64(data, c.priv, c.algorithm)
+		if err != nil {
+			t.Fatalf("algorithm %d: %v", c.algorithm, err)
+		}
+		if err := common.VerifyBase64(data, sig, c.pub, c.algorithm); err != nil {
+			t.Errorf("algorithm %d: verify error %v", c.algorithm, err)
+		}
+		if err := common.VerifyBase64([]byte("changed"), sig, c.pub, c.algorithm); !errors.Is(err, common.ErrSignature) {
+			t.Errorf("algorithm %d: changed data verified: %v", c.algorithm, err)
+		}
+
+		hexSig, err := common.SignHex(data, c.priv, c.algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := common.VerifyHex(data, hexSig, c.pub, c.algorithm); err != nil {
+			t.Errorf("algorithm %d: verify hex error %v", c.algorithm, err)
+		}
+	}
+
+	// PKCS #1 and SEC 1 keys
+	sig, err := common.Sign(data, rsaPriv1, common.RSASHA256, common.PKCS1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := common.Verify(data, sig, rsaPub1, common.RSASHA256, common.PKCS1); err != nil {
+		t.Errorf("PKCS1 verify error: %v", err)
+	}
+	sec1, _ := x509.MarshalECPrivateKey(p256)
+	sig, err = common.Sign(data, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}), common.ECDSASHA256, common.SEC1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := common.Verify(data, sig, p256Pub, common.ECDSASHA256, common.PKIX); err != nil {
+		t.Errorf("SEC1 verify error: %v", err)
+	}
+
+	// the same signature as the parameter signer
+	signer := &common.Signer{Algorithm: common.SignRSASHA256, Key: string(rsaPriv), PublicKey: string(rsaPub)}
+	params := map[string]string{"out_trade_no": "20150320010101001", "total_amount": "88.88"}
+	signed, _ := signer.Sign(params)
+	if sig, _ := common.SignBase64(data, rsaPriv, common.RSASHA256); sig != signed {
+		t.Errorf("signer signature differs: %s %s", sig, signed)
+	}
+
+	if _, err := common.Sign(data, rsaPriv, common.ECDSASHA256); err == nil {
+		t.Error("signed ECDSA with a rsa key")
+	}
+	// the curve must be the one of the hash
+	if _, err := common.Sign(data, p384Priv, common.ECDSASHA256); err == nil {
+		t.Error("signed ECDSASHA256 with a P-384 key")
+	}
+	if _, err := common.Sign(data, p256Priv, common.ECDSASHA384); err == nil {
+		t.Error("signed ECDSASHA384 with a P-256 key")
+	}
+	sig, _ = common.Sign(data, p384Priv, common.ECDSASHA384)
+	if err := common.Verify(data, sig, p384Pub, common.ECDSASHA256); err == nil || errors.Is(err, common.ErrSignature) {
+		t.Errorf("verified ECDSASHA256 with a P-384 key: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/kbrownehs18/gotools/common"
+)
+
+// encryptStream encrypt data with key, or with password if key is nil
+func encryptStream(t *testing.T, algorithm common.AEADAlgorithm, key []byte, password string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	if key != nil {
+		w, err = common.EncryptWriter(&buf, algorithm, key)
+	} else {
+		w, err = common.PasswordEncryptWriter(&buf, algorithm, password)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	// odd write sizes across chunk boundaries
+	for p := data; len(p) > 0; {
+		n := 1 + rand.Intn(100<<10)
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decryptStream(key []byte, b []byte) ([]byte, error) {
+	r, err := common.DecryptReader(bytes.NewReader(b), key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func TestStream(t *testing.T) {
+	key, _ := common.NewAEADKey()
+	for _, algorithm := range []common.AEADAlgorithm{common.AES256GCM, common.ChaCha20Poly1305} {
+		for _, size := range []int{0, 1, common.StreamChunkSize, 3*common.StreamChunkSize + 7, 5 << 20} {
+			data := make([]byte, size)
+			rand.Read(data)
+			b := encryptStream(t, algorithm, key, "", data)
+			plain, err := decryptStream(key, b)
+			if err != nil || !bytes.Equal(plain, data) {
+				t.Errorf("algorithm %d size %d: decrypt error %v", algorithm, size, err)
+			}
+		}
+	}
+}
+
+func TestStreamTamper(t *testing.T) {
+	key, _ := common.NewAEADKey()
+	data := make([]byte, 3*common.StreamChunkSize+100)
+	rand.Read(data)
+	b := encryptStream(t, common.AES256GCM, key, "", data)
+	sealed := common.StreamChunkSize + 16
+	header := len(b) - 3*sealed - (100 + 16)
+
+	modified := append([]byte(nil), b...)
+	modified[header+sealed+10] ^= 1
+	if _, err := decryptStream(key, modified); !errors.Is(err, common.ErrDecrypt) {
+		t.Errorf("modified chunk: %v", err)
+	}
+
+	// swap the first two chunks
+	swapped := append([]byte(nil), b[:header]...)
+	swapped = append(swapped, b[header+sealed:header+2*sealed]...)
+	swapped = append(swapped, b[header:header+sealed]...)
+	swapped = append(swapped, b[header+2*sealed:]...)
+	if _, err := decryptStream(key, swapped); !errors.Is(err, common.ErrDecrypt) {
+		t.Errorf("reordered chunks: %v", err)
+	}
+
+	modified = append([]byte(nil), b...)
+	modified[6] ^= 1
+	if _, err := decryptStream(key, modified); err == nil {
+		t.Error("modified header decrypted")
+	}
+
+	other, _ := common.NewAEADKey()
+	if _, err := decryptStream(other, b); !errors.Is(err, common.ErrDecrypt) {
+		t.Errorf("other key: %v", err)
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	key, _ := common.NewAEADKey()
+	data := make([]byte, 3*common.StreamChunkSize+100)
+	rand.Read(data)
+	b := encryptStream(t, common.ChaCha20Poly1305, key, "", data)
+	sealed := common.StreamChunkSize + 16
+	header := len(b) - 3*sealed - (100 + 16)
+
+	for _, n := range []int{header, header + sealed, header + 3*sealed} {
+		if _, err := decryptStream(key, b[:n]); !errors.Is(err, common.ErrTruncated) {
+			t.Errorf("cut at %d: %v", n, err)
+		}
+	}
+	if _, err := decryptStream(key, b[:len(b)-5]); !errors.Is(err, common.ErrDecrypt) {
+		t.Errorf("cut inside the last chunk: %v", err)
+	}
+	if _, err := decryptStream(key, b[:10]); err == nil {
+		t.Error("cut header decrypted")
+	}
+
+	// the plaintext before the cut is returned before the error
+	r, _ := common.DecryptReader(bytes.NewReader(b[:header+2*sealed]), key)
+	plain, err := ioutil.ReadAll(r)
+	if !errors.Is(err, common.ErrTruncated) || !bytes.Equal(plain, data[:common.StreamChunkSize]) {
+		t.Errorf("read before the cut: %d bytes, %v", len(plain), err)
+	}
+}
+
+func TestStreamPassword(t *testing.T) {
+	data := []byte("nightly backup")
+	b := encryptStream(t, common.AES256GCM, nil, "correct horse battery staple", data)
+
+	r, err := common.PasswordDecryptReader(bytes.NewReader(b), "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := ioutil.ReadAll(r); err != nil || !bytes.Equal(plain, data) {
+		t.Errorf("decrypt error %v", err)
+	}
+
+	r, err = common.PasswordDecryptReader(bytes.NewReader(b), "wrong password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(r); !errors.Is(err, common.ErrDecrypt) {
+		t.Errorf("wrong password: %v", err)
+	}
+
+	key, _ := common.NewAEADKey()
+	if _, err := common.DecryptReader(bytes.NewReader(b), key); err == nil {
+		t.Error("password stream decrypted with a key")
+	}
+	if _, err := common.PasswordEncryptWriter(ioutil.Discard, common.AES256GCM, ""); err == nil {
+		t.Error("empty password accepted")
+	}
+}
+
+func TestStreamHostileHeader(t *testing.T) {
+	header := func(time, memory uint32, threads byte) []byte {
+		b := append([]byte("GTSE"), 1, byte(common.AES256GCM), 1, 0, 1, 0, 0)
+		b = append(b, make([]byte, 16)...)
+		b = binary.BigEndian.AppendUint32(b, time)
+		b = binary.BigEndian.AppendUint32(b, memory)
+		return append(b, threads)
+	}
+
+	// each would take hours or gigabytes before the first chunk could be checked
+	for _, b := range [][]byte{
+		header(1<<32-1, 1024, 1),
+		header(1, 1<<20, 255),
+		header(1, 1<<32-1, 1),
+		header(1, 1024, 255),
+		header(0, 1024, 1),
+	} {
+		if _, err := common.PasswordDecryptReader(bytes.NewReader(b), "password"); err == nil {
+			t.Errorf("header % x accepted", b[27:])
+		}
+	}
+}
@@ -0,0 +1,98 @@
+<?php
+// Generates testdata/authcode-php.json for TestAuthcodePHP from the Discuz UCenter uc_authcode:
+//
+//	php tests/testdata/authcode.php > tests/testdata/authcode-php.json
+//
+// uc_authcode below is the UCenter client function unchanged except that $ckey_length,
+// fixed to 4 in UCenter, is a parameter. It runs in a namespace so its unqualified
+// microtime() and time() calls resolve to the overrides that return fixed values.
+
+namespace Discuz;
+
+define('UC_KEY', '');
+
+$fixedMicrotime = null;
+$fixedTime = null;
+
+function microtime() {
+	global $fixedMicrotime;
+	return $fixedMicrotime === null ? \microtime() : $fixedMicrotime;
+}
+
+function time() {
+	global $fixedTime;
+	return $fixedTime === null ? \time() : $fixedTime;
+}
+
+function uc_authcode($string, $operation = 'DECODE', $key = '', $expiry = 0, $ckey_length = 4) {
+	$key = md5($key ? $key : UC_KEY);
+	$keya = md5(substr($key, 0, 16));
+	$keyb = md5(substr($key, 16, 16));
+	$keyc = $ckey_length ? ($operation == 'DECODE' ? substr($string, 0, $ckey_length): substr(md5(microtime()), -$ckey_length)) : '';
+
+	$cryptkey = $keya.md5($keya.$keyc);
+	$key_length = strlen($cryptkey);
+
+	$string = $operation == 'DECODE' ? base64_decode(substr($string, $ckey_length)) : sprintf('%010d', $expiry ? $expiry + time() : 0).substr(md5($string.$keyb), 0, 16).$string;
+	$string_length = strlen($string);
+
+	$result = '';
+	$box = range(0, 255);
+
+	$rndkey = array();
+	for($i = 0; $i <= 255; $i++) {
+		$rndkey[$i] = ord($cryptkey[$i % $key_length]);
+	}
+
+	for($j = $i = 0; $i < 256; $i++) {
+		$j = ($j + $box[$i] + $rndkey[$i]) % 256;
+		$tmp = $box[$i];
+		$box[$i] = $box[$j];
+		$box[$j] = $tmp;
+	}
+
+	for($a = $j = $i = 0; $i < $string_length; $i++) {
+		$a = ($a + 1) % 256;
+		$j = ($j + $box[$a]) % 256;
+		$tmp = $box[$a];
+		$box[$a] = $box[$j];
+		$box[$j] = $tmp;
+		$result .= chr(ord($string[$i]) ^ ($box[($box[$a] + $box[$j]) % 256]));
+	}
+
+	if($operation == 'DECODE') {
+		if((substr($result, 0, 10) == 0 || substr($result, 0, 10) - time() > 0) && substr($result, 10, 16) == substr(md5(substr($result, 26).$keyb), 0, 16)) {
+			return substr($result, 26);
+		} else {
+			return '';
+		}
+	} else {
+		return $keyc.str_replace('=', '', base64_encode($result));
+	}
+}
+
+// text, key, expiry seconds, dynamic key length, microtime(), time()
+$cases = array(
+	array("scnjl", "1234567890", 0, 4, "0.12345600 1700000000", 1700000000),
+	array("uid=42\tadmin", "discuz", 3600, 4, "0.50000000 1700000000", 1700000000),
+	array("中文 & symbols=", "UC_KEY_abc", 0, 0, "0.00000100 1700000000", 1700000000),
+	array("scnjl", "1234567890", 0, 8, "0.99999900 1699999999", 1699999999),
+);
+
+$out = array('php' => PHP_VERSION, 'encode' => array(), 'decode' => array());
+foreach ($cases as $c) {
+	list($text, $key, $expiry, $ckeyLength, $fixedMicrotime, $fixedTime) = $c;
+	$out['encode'][] = array('text' => $text, 'key' => $key, 'expiry' => $expiry, 'key_length' => $ckeyLength,
+		'microtime' => $fixedMicrotime, 'time' => $fixedTime,
+		'ciphertext' => uc_authcode($text, 'ENCODE', $key, $expiry, $ckeyLength));
+}
+
+// real microtime(), texts encoded by php that Go must decode
+$fixedMicrotime = $fixedTime = null;
+foreach ($cases as $c) {
+	list($text, $key, , $ckeyLength) = $c;
+	$out['decode'][] = array('text' => $text, 'key' => $key, 'key_length' => $ckeyLength,
+		'ciphertext' => uc_authcode($text, 'ENCODE', $key, 0, $ckeyLength));
+}
+
+echo json_encode($out, JSON_PRETTY_PRINT | JSON_UNESCAPED_UNICODE | JSON_UNESCAPED_SLASHES), "\n";